@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nicobailon/treemux/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the treemux configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config and where each value came from",
+	Long: `Print the effective config for the current repository (or --repo) and the
+source of each value. Precedence, lowest first:
+
+  1. built-in defaults
+  2. ~/.config/treemux/config.yaml
+  3. .treemux.yaml at the repository root
+  4. the matching repos.<name> entry in ~/.config/treemux/config.yaml
+
+Hooks from every level run, in that order.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, g, _, _, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		repoRoot, _ := cmd.Flags().GetString("repo")
+		if repoRoot != "" {
+			if repoRoot, err = filepath.Abs(repoRoot); err != nil {
+				return err
+			}
+		} else if inGitRepo {
+			repoRoot = g.RepoRoot
+		}
+		effective, sources, err := cfg.Resolve(repoRoot)
+		// loadServices already warned about the current repository.
+		if err != nil && !(inGitRepo && repoRoot == g.RepoRoot) {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		printConfig(effective, sources)
+		return nil
+	},
+}
+
+func init() {
+	configShowCmd.Flags().String("repo", "", "Show the config for this repository instead of the current one")
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func printConfig(cfg *config.Config, sources config.Sources) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(key, value string) {
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, sources.Get(key))
+	}
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	row("base_branch", cfg.BaseBranch)
+	row("path_pattern", cfg.PathPattern)
+	row("session_name", cfg.SessionName)
+	row("theme", cfg.Theme)
+	row("search_paths", strings.Join(cfg.SearchPaths, ", "))
+	row("scan.max_depth", strconv.Itoa(cfg.Scan.MaxDepth))
+	row("scan.exclude", strings.Join(cfg.Scan.Exclude, ", "))
+	row("scan.follow_symlinks", strconv.FormatBool(cfg.Scan.FollowSymlinks))
+	row("layout", layoutSummary(cfg.Layout))
+	row("trusted_repos", strings.Join(cfg.TrustedRepos, ", "))
+	hooks := cfg.Hooks.Named()
+	events := make([]string, 0, len(hooks))
+	for event := range hooks {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	for _, event := range events {
+		row("hooks."+event, strings.Join(hooks[event], "; "))
+	}
+	row("restore.auto", strconv.FormatBool(cfg.Restore.Auto))
+	row("restore.commands", strings.Join(cfg.Restore.Commands, ", "))
+	w.Flush()
+}
+
+// layoutSummary describes a layout on one line, e.g.
+// "editor (nvim), dev (+1 pane)".
+func layoutSummary(layout *config.Layout) string {
+	if layout == nil {
+		return ""
+	}
+	var windows []string
+	for _, win := range layout.Windows {
+		var details []string
+		if win.Command != "" {
+			details = append(details, win.Command)
+		}
+		switch n := len(win.Panes); n {
+		case 0:
+		case 1:
+			details = append(details, "+1 pane")
+		default:
+			details = append(details, fmt.Sprintf("+%d panes", n))
+		}
+		name := win.Name
+		if name == "" {
+			name = "window"
+		}
+		if len(details) > 0 {
+			name += " (" + strings.Join(details, ", ") + ")"
+		}
+		windows = append(windows, name)
+	}
+	return strings.Join(windows, ", ")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nicobailon/treemux/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the repository index used by global mode",
+}
+
+var indexRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rescan search_paths and rebuild the repository index",
+	Long: `Rescan search_paths and rebuild the repository index.
+
+Global mode lists worktrees from the index and only re-lists repositories
+whose git metadata changed, so new repositories show up after the next
+background rescan (every few minutes) or after this command.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, _, _, _, _, err := loadServices()
+		if err != nil {
+			return err
+		}
+		ix, err := scanner.LoadIndex()
+		if err != nil {
+			return err
+		}
+		ix.Rebuild(cfg.SearchPaths, scanner.OptionsFor(cfg.Scan))
+		if err := ix.Save(); err != nil {
+			return err
+		}
+		worktrees := 0
+		for _, repo := range ix.Repos {
+			worktrees += len(repo.Worktrees)
+		}
+		fmt.Printf("Indexed %d repositories with %d worktrees in %s\n", len(ix.Repos), worktrees, scanner.IndexPath())
+		return nil
+	},
+}
+
+func init() {
+	indexCmd.AddCommand(indexRebuildCmd)
+	rootCmd.AddCommand(indexCmd)
+}
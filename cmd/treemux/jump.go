@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/recent"
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var jumpCmd = &cobra.Command{
+	Use:     "jump [query]",
+	Aliases: []string{"switch"},
+	Short:   "Jump to a worktree session by fuzzy name",
+	Long: `Jump to a worktree's tmux session without opening the TUI.
+
+The query is fuzzy-matched against the worktrees of the current repository
+and against recently used worktrees of other repositories. The session is
+created if it does not exist. When several worktrees match, a picker is
+shown.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, _, t, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		store, _ := recent.Load()
+
+		var candidates []jumpCandidate
+		if inGitRepo {
+			wts, err := svc.Git.WorktreeList()
+			if err != nil {
+				return err
+			}
+			for _, wt := range wts {
+				candidates = append(candidates, jumpCandidate{
+					RepoRoot: svc.Git.RepoRoot,
+					Name:     wt.Name,
+					Branch:   wt.Branch,
+					Path:     wt.Path,
+				})
+			}
+		}
+		if store != nil {
+			candidates = appendRecentCandidates(candidates, store.Entries)
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("no worktrees to jump to")
+		}
+
+		query := ""
+		if len(args) > 0 {
+			query = args[0]
+		}
+		matches := matchJumpCandidates(candidates, query)
+		var target jumpCandidate
+		switch len(matches) {
+		case 0:
+			return fmt.Errorf("no worktree matches %q", query)
+		case 1:
+			target = matches[0]
+		default:
+			target, err = pickJumpCandidate(matches)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !inGitRepo || target.RepoRoot != svc.Git.RepoRoot {
+			svc = workspace.NewServiceForRepo(target.RepoRoot, t, cfg, t.Cmd)
+			warnConfig(svc)
+		}
+		sessionName, err := svc.PrepareJump(target.Path)
+		if err != nil {
+			return err
+		}
+		if store != nil {
+			store.Add(target.RepoRoot, target.Name, sessionName, target.Path)
+			_ = store.Save()
+		}
+		if t.IsInsideTmux() {
+			return t.SwitchClient(sessionName)
+		}
+		return attachToSession(sessionName)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jumpCmd)
+}
+
+type jumpCandidate struct {
+	RepoRoot string
+	Name     string
+	Branch   string
+	Path     string
+}
+
+func (c jumpCandidate) label() string {
+	label := git.RepoName(c.RepoRoot) + "/" + c.Name
+	if c.Branch != "" && c.Branch != c.Name {
+		label += " [" + c.Branch + "]"
+	}
+	return label
+}
+
+// appendRecentCandidates adds recent entries whose worktree still exists and
+// is not already a candidate, most recently used first.
+func appendRecentCandidates(candidates []jumpCandidate, entries []recent.Entry) []jumpCandidate {
+	entries = append([]recent.Entry{}, entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastAccess.After(entries[j].LastAccess)
+	})
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		seen[c.Path] = true
+	}
+	for _, e := range entries {
+		if e.Path == "" || seen[e.Path] {
+			continue
+		}
+		if _, err := os.Stat(e.Path); err != nil {
+			continue
+		}
+		seen[e.Path] = true
+		candidates = append(candidates, jumpCandidate{RepoRoot: e.RepoRoot, Name: e.Worktree, Path: e.Path})
+	}
+	return candidates
+}
+
+// matchJumpCandidates returns the candidates matching query, best first. An
+// exact name or branch match wins over fuzzy matches.
+func matchJumpCandidates(candidates []jumpCandidate, query string) []jumpCandidate {
+	if query == "" {
+		return candidates
+	}
+	var exact []jumpCandidate
+	for _, c := range candidates {
+		if strings.EqualFold(c.Name, query) || strings.EqualFold(c.Branch, query) {
+			exact = append(exact, c)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	targets := make([]string, len(candidates))
+	for i, c := range candidates {
+		targets[i] = c.label()
+	}
+	var matches []jumpCandidate
+	for _, rank := range list.DefaultFilter(query, targets) {
+		matches = append(matches, candidates[rank.Index])
+	}
+	return matches
+}
+
+func pickJumpCandidate(matches []jumpCandidate) (jumpCandidate, error) {
+	if !stdinIsTerminal() {
+		return jumpCandidate{}, fmt.Errorf("%d worktrees match; be more specific", len(matches))
+	}
+	for i, c := range matches {
+		fmt.Fprintf(os.Stderr, "%3d) %s\n", i+1, c.label())
+	}
+	fmt.Fprint(os.Stderr, "Jump to: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return jumpCandidate{}, fmt.Errorf("no worktree selected")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(matches) {
+		return jumpCandidate{}, fmt.Errorf("no worktree selected")
+	}
+	return matches[n-1], nil
+}
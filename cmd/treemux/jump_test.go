@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/recent"
+)
+
+func TestMatchJumpCandidates(t *testing.T) {
+	candidates := []jumpCandidate{
+		{RepoRoot: "/src/app", Name: "app", Branch: "main", Path: "/src/app"},
+		{RepoRoot: "/src/app", Name: "app-login", Branch: "feat/login", Path: "/src/app-login"},
+		{RepoRoot: "/src/app", Name: "app-logout", Branch: "feat/logout", Path: "/src/app-logout"},
+		{RepoRoot: "/src/api", Name: "api", Branch: "main", Path: "/src/api"},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"app", "app-login", "app-logout", "api"}},
+		{"APP-LOGIN", []string{"app-login"}},
+		{"feat/logout", []string{"app-logout"}},
+		{"main", []string{"app", "api"}},
+		{"app-log", []string{"app-login", "app-logout"}},
+		{"lgout", []string{"app-logout"}},
+		{"zzz", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, c := range matchJumpCandidates(candidates, tt.query) {
+			got = append(got, c.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAppendRecentCandidatesByRecency(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	entries := []recent.Entry{
+		{RepoRoot: "/src/web", Worktree: "old", Path: dir, LastAccess: now.Add(-time.Hour)},
+		{RepoRoot: "/src/web", Worktree: "gone", Path: filepath.Join(dir, "missing"), LastAccess: now},
+		{RepoRoot: "/src/web", Worktree: "new", Path: t.TempDir(), LastAccess: now.Add(-time.Minute)},
+		{RepoRoot: "/src/app", Worktree: "app", Path: "/src/app", LastAccess: now},
+	}
+	local := []jumpCandidate{{RepoRoot: "/src/app", Name: "app", Path: "/src/app"}}
+	var got []string
+	for _, c := range appendRecentCandidates(local, entries) {
+		got = append(got, c.Name)
+	}
+	if strings.Join(got, ",") != "app,new,old" {
+		t.Fatalf("got %v, want app,new,old", got)
+	}
+}
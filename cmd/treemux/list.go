@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List worktrees and orphaned sessions",
+	Long: `List worktrees and orphaned sessions.
+
+--format selects the output:
+  table     human readable table (default)
+  json      one JSON document with worktrees and orphans
+  tsv       one tab separated line per worktree and orphan, no header:
+            worktree  name  branch  path  session  has_session  staged  modified  untracked  ahead  behind
+            orphan    session
+  template  anything else is a Go template executed once per worktree,
+            e.g. '{{.Name}} {{.Session.Name}}'. Fields are the Go names of
+            the JSON keys: Name, Path, Branch, Current, Session.Exists,
+            Status.Staged, Ahead, Behind, Processes, Commits, ...`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, g, _, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		if !inGitRepo {
+			return fmt.Errorf("not in a git repository")
+		}
+		format, _ := cmd.Flags().GetString("format")
+		return listAction(g, svc, format)
+	},
+}
+
+func init() {
+	listCmd.Flags().String("format", "table", "Output format: table, json, tsv or a Go template")
+	rootCmd.AddCommand(listCmd)
+}
+
+// listOutput is the schema of `treemux list --format json`. Fields are only
+// ever added; bump listSchemaVersion when changing existing ones.
+const listSchemaVersion = 1
+
+type listOutput struct {
+	SchemaVersion int            `json:"schema_version"`
+	RepoRoot      string         `json:"repo_root"`
+	Worktrees     []listWorktree `json:"worktrees"`
+	Orphans       []string       `json:"orphans"`
+}
+
+type listWorktree struct {
+	Name           string       `json:"name"`
+	Path           string       `json:"path"`
+	Branch         string       `json:"branch"`
+	Head           string       `json:"head"`
+	Detached       bool         `json:"detached"`
+	Locked         bool         `json:"locked"`
+	LockReason     string       `json:"lock_reason"`
+	Prunable       bool         `json:"prunable"`
+	PrunableReason string       `json:"prunable_reason"`
+	Current        bool         `json:"current"`
+	Session        listSession  `json:"session"`
+	Status         *listStatus  `json:"status"`
+	Ahead          int          `json:"ahead"`
+	Behind         int          `json:"behind"`
+	Processes      []string     `json:"processes"`
+	Commits        []listCommit `json:"commits"`
+}
+
+type listSession struct {
+	Name         string     `json:"name"`
+	Exists       bool       `json:"exists"`
+	Windows      int        `json:"windows"`
+	Panes        int        `json:"panes"`
+	Attached     bool       `json:"attached"`
+	LastActivity *time.Time `json:"last_activity"`
+}
+
+type listStatus struct {
+	Clean      bool   `json:"clean"`
+	Staged     int    `json:"staged"`
+	Modified   int    `json:"modified"`
+	Untracked  int    `json:"untracked"`
+	Conflicted int    `json:"conflicted"`
+	Renamed    int    `json:"renamed"`
+	Upstream   string `json:"upstream"`
+	Stashes    int    `json:"stashes"`
+	Operation  string `json:"operation"`
+}
+
+type listCommit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+}
+
+func listAction(g *git.Git, svc *workspace.Service, format string) error {
+	states, orphans, err := svc.List()
+	if err != nil {
+		return err
+	}
+	orphans = append([]string{}, orphans...)
+	sort.Strings(orphans)
+
+	switch format {
+	case "", "table":
+		printListTable(g.RepoRoot, states, orphans)
+		return nil
+	case "json":
+		return writeListJSON(os.Stdout, buildListOutput(g.RepoRoot, states, orphans))
+	case "tsv":
+		return writeListTSV(os.Stdout, buildListOutput(g.RepoRoot, states, orphans))
+	}
+	if !strings.Contains(format, "{{") {
+		return fmt.Errorf("unknown format %q (want table, json, tsv or a Go template)", format)
+	}
+	tmpl, err := template.New("list").Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	for _, wt := range buildListOutput(g.RepoRoot, states, orphans).Worktrees {
+		if err := tmpl.Execute(os.Stdout, wt); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(format, "\n") {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+func printListTable(current string, states []workspace.WorktreeState, orphans []string) {
+	fmt.Println()
+	fmt.Println("Worktrees")
+	for _, wt := range states {
+		mark := " "
+		if wt.Worktree.Path == current {
+			mark = "●"
+		}
+		sessionLabel := ""
+		if !wt.HasSession {
+			sessionLabel = " (no session)"
+		} else if wt.SessionName != wt.Worktree.Name {
+			sessionLabel = " (session " + wt.SessionName + ")"
+		}
+		branch := wt.Worktree.Branch
+		if wt.Worktree.Detached {
+			branch = "(detached)"
+		}
+		if wt.Worktree.Locked {
+			sessionLabel += " [locked]"
+		}
+		if wt.Worktree.Prunable {
+			sessionLabel += " [prunable]"
+		}
+		fmt.Printf(" %s %-20s %-12s%s\n", mark, wt.Worktree.Name, branch, sessionLabel)
+	}
+	if len(orphans) > 0 {
+		fmt.Println()
+		fmt.Println("Orphaned sessions (no worktree)")
+		for _, o := range orphans {
+			fmt.Printf(" - %s\n", o)
+		}
+	}
+	fmt.Println()
+}
+
+func buildListOutput(repoRoot string, states []workspace.WorktreeState, orphans []string) listOutput {
+	out := listOutput{
+		SchemaVersion: listSchemaVersion,
+		RepoRoot:      repoRoot,
+		Worktrees:     []listWorktree{},
+		Orphans:       orphans,
+	}
+	if out.Orphans == nil {
+		out.Orphans = []string{}
+	}
+	for _, st := range states {
+		wt := listWorktree{
+			Name:           st.Worktree.Name,
+			Path:           st.Worktree.Path,
+			Branch:         st.Worktree.Branch,
+			Head:           st.Worktree.Head,
+			Detached:       st.Worktree.Detached,
+			Locked:         st.Worktree.Locked,
+			LockReason:     st.Worktree.LockReason,
+			Prunable:       st.Worktree.Prunable,
+			PrunableReason: st.Worktree.PrunableReason,
+			Current:        st.Worktree.Path == repoRoot,
+			Session:        listSession{Name: st.SessionName, Exists: st.HasSession},
+			Ahead:          st.Ahead,
+			Behind:         st.Behind,
+			Processes:      []string{},
+			Commits:        []listCommit{},
+		}
+		if st.HasSession && st.SessionInfo != nil {
+			wt.Session.Windows = st.SessionInfo.Windows
+			wt.Session.Panes = st.SessionInfo.Panes
+			wt.Session.Attached = st.SessionInfo.IsActive
+			if !st.SessionInfo.LastActivity.IsZero() {
+				last := st.SessionInfo.LastActivity
+				wt.Session.LastActivity = &last
+			}
+		}
+		if st.Status != nil {
+			wt.Status = &listStatus{
+				Clean:      st.Status.Clean,
+				Staged:     st.Status.Staged,
+				Modified:   st.Status.Modified,
+				Untracked:  st.Status.Untracked,
+				Conflicted: st.Status.Conflicted,
+				Renamed:    st.Status.Renamed,
+				Upstream:   st.Status.Upstream,
+				Stashes:    st.Status.Stashes,
+				Operation:  st.Status.Operation,
+			}
+		}
+		if st.HasSession {
+			wt.Processes = append(wt.Processes, st.Processes...)
+		}
+		for _, c := range st.Commits {
+			wt.Commits = append(wt.Commits, listCommit{Hash: c.Hash, Subject: c.Msg})
+		}
+		out.Worktrees = append(out.Worktrees, wt)
+	}
+	return out
+}
+
+func writeListJSON(w io.Writer, out listOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func writeListTSV(w io.Writer, out listOutput) error {
+	for _, wt := range out.Worktrees {
+		var staged, modified, untracked int
+		if wt.Status != nil {
+			staged, modified, untracked = wt.Status.Staged, wt.Status.Modified, wt.Status.Untracked
+		}
+		fields := []string{
+			"worktree",
+			wt.Name,
+			wt.Branch,
+			wt.Path,
+			wt.Session.Name,
+			strconv.FormatBool(wt.Session.Exists),
+			strconv.Itoa(staged),
+			strconv.Itoa(modified),
+			strconv.Itoa(untracked),
+			strconv.Itoa(wt.Ahead),
+			strconv.Itoa(wt.Behind),
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	for _, o := range out.Orphans {
+		if _, err := fmt.Fprintf(w, "orphan\t%s\n", o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
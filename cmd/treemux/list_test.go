@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/tmux"
+	"github.com/nicobailon/treemux/internal/workspace"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func listFixture() listOutput {
+	states := []workspace.WorktreeState{
+		{
+			Worktree:    git.Worktree{Path: "/src/app", Name: "app", Branch: "main", Head: "1111111111111111111111111111111111111111"},
+			SessionName: "app",
+			HasSession:  true,
+			Status:      &git.StatusSummary{Clean: true, Upstream: "origin/main"},
+			SessionInfo: &tmux.SessionInfo{Windows: 2, Panes: 3, IsActive: true, LastActivity: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+			Processes:   []string{"nvim"},
+			Commits:     []git.Commit{{Hash: "1111111", Msg: "Initial commit"}},
+		},
+		{
+			Worktree:    git.Worktree{Path: "/src/app-feat", Name: "app-feat", Branch: "feat/login", Head: "2222222222222222222222222222222222222222", Locked: true, LockReason: "on a usb stick"},
+			SessionName: "app-feat",
+			Status:      &git.StatusSummary{Staged: 1, Modified: 2, Untracked: 3, Conflicted: 1, Stashes: 1, Operation: "merge"},
+			Ahead:       4,
+			Behind:      5,
+		},
+		{
+			Worktree:    git.Worktree{Path: "/src/app-old", Name: "app-old", Head: "3333333333333333333333333333333333333333", Detached: true, Prunable: true, PrunableReason: "gitdir file points to non-existent location"},
+			SessionName: "app-old",
+		},
+	}
+	return buildListOutput("/src/app", states, []string{"stale"})
+}
+
+func TestListOutputGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		write  func(io.Writer, listOutput) error
+	}{
+		{"list.json", writeListJSON},
+		{"list.tsv", writeListTSV},
+	}
+	for _, tt := range tests {
+		var got bytes.Buffer
+		if err := tt.write(&got, listFixture()); err != nil {
+			t.Fatalf("%s: %v", tt.golden, err)
+		}
+		path := filepath.Join("testdata", tt.golden)
+		if *update {
+			if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read golden file: %v", err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("%s mismatch (run go test -update to accept):\n%s", tt.golden, got.String())
+		}
+	}
+}
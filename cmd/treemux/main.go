@@ -2,13 +2,14 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
 
 	"github.com/nicobailon/treemux/internal/config"
 	"github.com/nicobailon/treemux/internal/deps"
 	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/sessions"
 	"github.com/nicobailon/treemux/internal/shell"
 	"github.com/nicobailon/treemux/internal/tmux"
 	"github.com/nicobailon/treemux/internal/tui"
@@ -26,6 +27,9 @@ var (
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if hint := workspace.Hint(err); hint != "" {
+			fmt.Fprintln(os.Stderr, "hint: "+hint)
+		}
 		os.Exit(1)
 	}
 }
@@ -43,7 +47,6 @@ func init() {
 	rootCmd.Flags().BoolVarP(&listFlag, "list", "l", false, "List worktrees and orphaned sessions")
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Show version")
 
-	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(cleanCmd)
 }
 
@@ -73,9 +76,18 @@ func loadServices() (*config.Config, *git.Git, *tmux.Tmux, *workspace.Service, b
 		return cfg, nil, t, nil, false, nil
 	}
 	svc := workspace.NewService(g, t, cfg, cmd)
+	warnConfig(svc)
 	return cfg, g, t, svc, true, nil
 }
 
+// warnConfig prints the config warning of svc, such as ignored project
+// hooks, to stderr.
+func warnConfig(svc *workspace.Service) {
+	if svc.ConfigWarning != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", svc.ConfigWarning)
+	}
+}
+
 func runRoot(cmd *cobra.Command, args []string) error {
 	cfg, g, t, svc, inGitRepo, err := loadServices()
 	if err != nil {
@@ -91,7 +103,13 @@ func runRoot(cmd *cobra.Command, args []string) error {
 		if !inGitRepo {
 			return fmt.Errorf("not in a git repository")
 		}
-		return listAction(g, svc)
+		return listAction(g, svc, "table")
+	}
+
+	if cfg.Restore.Auto && !newSession {
+		if _, err := restoreSessions(cfg, t, io.Discard); err != nil {
+			fmt.Fprintf(os.Stderr, "restoring sessions failed: %v\n", err)
+		}
 	}
 
 	if inGitRepo && !t.IsInsideTmux() && !newSession {
@@ -104,6 +122,9 @@ func runRoot(cmd *cobra.Command, args []string) error {
 				return err
 			}
 			if target != nil {
+				if err := startTargetSession(cfg, t, svc, target); err != nil {
+					return err
+				}
 				return attachToSession(target.SessionName)
 			}
 			return nil
@@ -117,10 +138,8 @@ func runRoot(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	if target != nil {
-		if target.Create {
-			if err := t.NewSession(target.SessionName, target.Path); err != nil {
-				return fmt.Errorf("failed to create session: %w", err)
-			}
+		if err := startTargetSession(cfg, t, svc, target); err != nil {
+			return err
 		}
 		if t.IsInsideTmux() {
 			return t.SwitchClient(target.SessionName)
@@ -130,6 +149,20 @@ func runRoot(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func startTargetSession(cfg *config.Config, t *tmux.Tmux, svc *workspace.Service, target *tui.JumpTarget) error {
+	if !target.Create {
+		return nil
+	}
+	if svc == nil || (target.RepoRoot != "" && target.RepoRoot != svc.Git.RepoRoot) {
+		svc = workspace.NewServiceForRepo(target.RepoRoot, t, cfg, t.Cmd)
+		warnConfig(svc)
+	}
+	if err := svc.StartSession(target.SessionName, target.Path); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
 func attachToSession(name string) error {
 	cmd := exec.Command("tmux", "attach", "-t", name)
 	cmd.Stdin = os.Stdin
@@ -139,7 +172,7 @@ func attachToSession(name string) error {
 }
 
 func bootstrapTmux(repoRoot string) error {
-	sessionName := filepath.Base(repoRoot)
+	sessionName := git.RepoName(repoRoot)
 	exePath, err := os.Executable()
 	if err != nil {
 		return err
@@ -160,51 +193,6 @@ func bootstrapTmux(repoRoot string) error {
 	return nil
 }
 
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List worktrees and orphaned sessions",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		_, g, _, svc, inGitRepo, err := loadServices()
-		if err != nil {
-			return err
-		}
-		if !inGitRepo {
-			return fmt.Errorf("not in a git repository")
-		}
-		return listAction(g, svc)
-	},
-}
-
-func listAction(g *git.Git, svc *workspace.Service) error {
-	current := g.RepoRoot
-	states, orphans, err := svc.List()
-	if err != nil {
-		return err
-	}
-	fmt.Println()
-	fmt.Println("Worktrees")
-	for _, wt := range states {
-		mark := " "
-		if wt.Worktree.Path == current {
-			mark = "●"
-		}
-		sessionLabel := ""
-		if !wt.HasSession {
-			sessionLabel = " (no session)"
-		}
-		fmt.Printf(" %s %-20s %-12s%s\n", mark, wt.Worktree.Name, wt.Worktree.Branch, sessionLabel)
-	}
-	if len(orphans) > 0 {
-		fmt.Println()
-		fmt.Println("Orphaned sessions (no worktree)")
-		for _, o := range orphans {
-			fmt.Printf(" - %s\n", o)
-		}
-	}
-	fmt.Println()
-	return nil
-}
-
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Find and fix orphaned tmux sessions / worktrees without sessions",
@@ -223,14 +211,26 @@ var cleanCmd = &cobra.Command{
 
 		kill, _ := cmd.Flags().GetBool("kill-orphans")
 
+		store, err := sessions.Load()
+		if err != nil {
+			store = &sessions.Store{}
+		}
 		fixed := false
 		for _, wt := range states {
-			if !wt.HasSession {
-				if err := t.NewSession(wt.SessionName, wt.Worktree.Path); err == nil {
-					fmt.Printf("Created session for worktree: %s\n", wt.SessionName)
+			if wt.HasSession {
+				continue
+			}
+			if saved, ok := store.Get(wt.Worktree.Path); ok {
+				if _, err := svc.RestoreSession(saved); err == nil {
+					fmt.Printf("Restored session for worktree: %s\n", wt.SessionName)
 					fixed = true
+					continue
 				}
 			}
+			if err := svc.StartSession(wt.SessionName, wt.Worktree.Path); err == nil {
+				fmt.Printf("Created session for worktree: %s\n", wt.SessionName)
+				fixed = true
+			}
 		}
 
 		if len(orphans) == 0 && !fixed {
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nicobailon/treemux/internal/recent"
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:     "mv <name|path> <new-name>",
+	Aliases: []string{"rename"},
+	Short:   "Rename a worktree, its branch and its tmux session",
+	Long: `Rename a worktree without deleting and recreating it.
+
+The worktree is moved to where path_pattern puts new-name (git worktree
+move), its branch is renamed to new-name (git branch -m) and its session is
+renamed to match (tmux rename-session). Recent worktrees and saved session
+layouts follow the rename. If a step fails, the earlier ones are undone.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, _, _, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		if !inGitRepo {
+			return fmt.Errorf("not in a git repository")
+		}
+		keepBranch, _ := cmd.Flags().GetBool("keep-branch")
+
+		opts := workspace.RenameOptions{KeepBranch: keepBranch}
+		if store, err := recent.Load(); err == nil {
+			opts.Recent = store
+		}
+		result, err := svc.Rename(args[0], args[1], opts)
+		if err != nil {
+			return err
+		}
+		if result.Path != result.OldPath {
+			fmt.Printf("Moved worktree: %s -> %s\n", result.OldPath, result.Path)
+		}
+		if result.Branch != result.OldBranch {
+			fmt.Printf("Renamed branch: %s -> %s\n", result.OldBranch, result.Branch)
+		}
+		if result.Session != result.OldSession {
+			fmt.Printf("Renamed session: %s -> %s\n", result.OldSession, result.Session)
+		}
+		return nil
+	},
+}
+
+func init() {
+	mvCmd.Flags().Bool("keep-branch", false, "Keep the branch name; only move the folder and session")
+	rootCmd.AddCommand(mvCmd)
+}
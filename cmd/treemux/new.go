@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new [name]",
+	Short: "Create a worktree and its tmux session",
+	Long: `Create a worktree and its tmux session without opening the TUI.
+
+Prints the worktree path on the first line and the session name on the
+second (omitted with --no-session).
+
+--from-remote origin/feature creates a local branch tracking the remote
+branch; --pr 123 (or any ref, e.g. refs/pull/123/head) fetches the ref from
+--remote into a new branch. With either, the name defaults to the remote
+branch name or pr-<n>.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, _, t, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		if !inGitRepo {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		base, _ := cmd.Flags().GetString("base")
+		existing, _ := cmd.Flags().GetBool("from-existing-branch")
+		noSession, _ := cmd.Flags().GetBool("no-session")
+		switchTo, _ := cmd.Flags().GetBool("switch")
+		fromRemote, _ := cmd.Flags().GetString("from-remote")
+		pr, _ := cmd.Flags().GetString("pr")
+		remote, _ := cmd.Flags().GetString("remote")
+		if noSession && switchTo {
+			return fmt.Errorf("--switch cannot be combined with --no-session")
+		}
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		opts := workspace.CreateOptions{
+			Base:           base,
+			ExistingBranch: existing,
+			RemoteBranch:   fromRemote,
+			Remote:         remote,
+			NoSession:      noSession,
+		}
+		if pr != "" {
+			opts.Ref = git.PullRequestRef(pr)
+		}
+		if name == "" {
+			name = defaultWorktreeName(fromRemote, pr)
+		}
+		if name == "" {
+			return fmt.Errorf("a worktree name is required")
+		}
+
+		svc.HookLog = os.Stderr
+		path, sessionName, err := svc.Create(name, opts)
+		if path == "" {
+			return err
+		}
+		fmt.Println(path)
+		if sessionName != "" {
+			fmt.Println(sessionName)
+		}
+		if err != nil {
+			return err
+		}
+
+		if switchTo {
+			if t.IsInsideTmux() {
+				return t.SwitchClient(sessionName)
+			}
+			return attachToSession(sessionName)
+		}
+		return nil
+	},
+}
+
+// defaultWorktreeName derives a name from --from-remote or --pr when none is
+// given.
+func defaultWorktreeName(remoteBranch, pr string) string {
+	if _, branch, ok := strings.Cut(remoteBranch, "/"); ok {
+		return branch
+	}
+	if pr != "" {
+		if ref := git.PullRequestRef(pr); strings.HasPrefix(ref, "refs/pull/") {
+			return "pr-" + strings.TrimSuffix(strings.TrimPrefix(ref, "refs/pull/"), "/head")
+		}
+	}
+	return ""
+}
+
+func init() {
+	newCmd.Flags().String("base", "", "Branch to start the new branch from (default: base_branch)")
+	newCmd.Flags().Bool("from-existing-branch", false, "Check out the existing branch <name> instead of creating it")
+	newCmd.Flags().Bool("no-session", false, "Create the worktree without a tmux session")
+	newCmd.Flags().Bool("switch", false, "Switch to (or attach) the new session")
+	newCmd.Flags().String("from-remote", "", "Track a remote branch such as origin/feature")
+	newCmd.Flags().String("pr", "", "Fetch a pull request number or ref from --remote")
+	newCmd.Flags().String("remote", "origin", "Remote to fetch --pr from")
+	newCmd.MarkFlagsMutuallyExclusive("base", "from-existing-branch", "from-remote", "pr")
+	rootCmd.AddCommand(newCmd)
+}
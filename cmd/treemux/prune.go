@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove merged, gone-upstream or stale worktrees",
+	Long: `Classify every worktree as merged, gone (upstream deleted), stale or
+active, then remove the selected ones together with their sessions and
+branches.
+
+Merged and gone worktrees are selected by default; add --stale to include
+worktrees without activity for --stale-weeks. Worktrees with local changes or
+running processes are always active and never pruned. Gone branches are
+force deleted only when their changes were squash-merged into the base branch;
+they and stale branches are otherwise only deleted if merged.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, _, _, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		if !inGitRepo {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		includeStale, _ := cmd.Flags().GetBool("stale")
+		weeks, _ := cmd.Flags().GetInt("stale-weeks")
+		keepBranch, _ := cmd.Flags().GetBool("keep-branch")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		states, _, err := svc.List()
+		if err != nil {
+			return err
+		}
+		staleAfter := time.Duration(weeks) * 7 * 24 * time.Hour
+		var selected []workspace.PruneCandidate
+		for _, c := range svc.Classify(states, staleAfter) {
+			mark := " "
+			if c.Reason == workspace.PruneMerged || c.Reason == workspace.PruneGone || (includeStale && c.Reason == workspace.PruneStale) {
+				mark = "x"
+				selected = append(selected, c)
+			}
+			fmt.Printf("[%s] %-7s %-24s %-24s %s\n", mark, c.Reason, c.State.Worktree.Name, c.Branch.Name, lastActivityLabel(c.LastActivity))
+		}
+		if len(selected) == 0 {
+			fmt.Println("Nothing to prune")
+			return nil
+		}
+		if dryRun {
+			return nil
+		}
+		if !yes && !confirm(fmt.Sprintf("Remove %d worktree(s), their sessions and branches?", len(selected))) {
+			return fmt.Errorf("aborted (use --yes to prune without confirmation)")
+		}
+
+		svc.HookLog = os.Stderr
+		var failed int
+		for _, c := range selected {
+			result, err := svc.Prune(c, keepBranch)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", c.State.Worktree.Name, err)
+				continue
+			}
+			line := "Removed " + c.State.Worktree.Name
+			switch {
+			case result.BranchDeleted:
+				line += ", deleted branch " + result.Branch
+			case result.KeptReason != "":
+				line += ", kept branch: " + result.KeptReason
+			}
+			fmt.Println(line)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d worktree(s) could not be pruned", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	pruneCmd.Flags().Bool("stale", false, "Also prune stale worktrees")
+	pruneCmd.Flags().Int("stale-weeks", int(workspace.DefaultStaleAfter/(7*24*time.Hour)), "Weeks without commits or session activity before a worktree is stale")
+	pruneCmd.Flags().Bool("keep-branch", false, "Remove worktrees and sessions but keep the branches")
+	pruneCmd.Flags().Bool("dry-run", false, "Only show the classification")
+	pruneCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func lastActivityLabel(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// confirm asks a yes/no question on stderr. Without a terminal the answer is
+// no.
+func confirm(question string) bool {
+	if !stdinIsTerminal() {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nicobailon/treemux/internal/config"
+	"github.com/nicobailon/treemux/internal/sessions"
+	"github.com/nicobailon/treemux/internal/tmux"
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Rebuild saved worktree sessions after a reboot or tmux crash",
+	Long: `Recreate every saved worktree session that is not running: its windows,
+panes, layouts and working directories. Commands listed under
+restore.commands in the config are restarted in their panes.
+
+Sessions are saved while the TUI runs and by 'treemux save'. Saved sessions
+of worktrees that no longer exist are forgotten.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, _, t, _, _, err := loadServices()
+		if err != nil {
+			return err
+		}
+		restored, err := restoreSessions(cfg, t, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if restored == 0 {
+			fmt.Println("Nothing to restore")
+		}
+		return nil
+	},
+}
+
+var saveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Save the layout of this repository's worktree sessions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, _, _, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		if !inGitRepo {
+			return fmt.Errorf("not in a git repository")
+		}
+		states, _, err := svc.List()
+		if err != nil {
+			return err
+		}
+		if err := svc.SaveSessions(states); err != nil {
+			return err
+		}
+		fmt.Printf("Saved sessions to %s\n", sessions.Path())
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(saveCmd)
+}
+
+// restoreSessions rebuilds the saved sessions that are not running and
+// returns how many were restored.
+func restoreSessions(cfg *config.Config, t *tmux.Tmux, out io.Writer) (int, error) {
+	store, err := sessions.Load()
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	var gone []string
+	for _, saved := range store.Sessions {
+		if fi, err := os.Stat(saved.Path); err != nil || !fi.IsDir() {
+			fmt.Fprintf(out, "Forgot %s: worktree %s no longer exists\n", saved.Name, saved.Path)
+			gone = append(gone, saved.Path)
+			continue
+		}
+		svc := workspace.NewServiceForRepo(saved.RepoRoot, t, cfg, t.Cmd)
+		if _, running, _ := svc.SessionFor(saved.Path); running {
+			continue
+		}
+		name, err := svc.RestoreSession(saved)
+		if err != nil {
+			fmt.Fprintf(out, "Failed to restore %s: %v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(out, "Restored session %s (%s)\n", name, saved.Path)
+		restored++
+	}
+	if len(gone) > 0 {
+		err = sessions.Update(func(store *sessions.Store) {
+			for _, path := range gone {
+				store.Remove(path)
+			}
+		})
+	}
+	return restored, err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nicobailon/treemux/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <name|path>",
+	Short: "Delete a worktree and its tmux session",
+	Long: `Delete a worktree and its tmux session without opening the TUI.
+
+Refuses to delete worktrees with uncommitted changes, unpushed commits or
+running non-shell processes unless --force is given. Locked worktrees are
+refused until they are unlocked.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, g, _, svc, inGitRepo, err := loadServices()
+		if err != nil {
+			return err
+		}
+		if !inGitRepo {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		deleteBranch, _ := cmd.Flags().GetBool("delete-branch")
+		forceDeleteBranch, _ := cmd.Flags().GetBool("force-delete-branch")
+		deleteRemote, _ := cmd.Flags().GetBool("delete-remote")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		opts := workspace.DeleteOptions{Force: force, DeleteRemote: deleteRemote}
+		switch {
+		case forceDeleteBranch:
+			opts.Branch = workspace.ForceDeleteBranch
+		case deleteBranch:
+			opts.Branch = workspace.DeleteMergedBranch
+		}
+		if deleteRemote && opts.Branch == workspace.KeepBranch {
+			return fmt.Errorf("--delete-remote requires --delete-branch or --force-delete-branch")
+		}
+
+		check, err := svc.CheckDelete(args[0])
+		if err != nil {
+			return err
+		}
+		wt := check.Worktree
+		if filepath.Clean(wt.Path) == filepath.Clean(g.MainWorktree()) {
+			return fmt.Errorf("cannot delete the main worktree")
+		}
+
+		problems := check.Problems()
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", wt.Name, p)
+		}
+
+		if dryRun {
+			if check.HasSession {
+				fmt.Printf("Would kill session: %s\n", check.SessionName)
+			}
+			fmt.Printf("Would remove worktree: %s\n", wt.Path)
+			if opts.Branch != workspace.KeepBranch {
+				printBranchPlan(svc.BranchInfo(wt.Path), opts)
+			}
+			switch {
+			case wt.Locked:
+				fmt.Println("Refusing while the worktree is locked")
+			case len(problems) > 0 && !force:
+				fmt.Println("Refusing without --force")
+			}
+			return nil
+		}
+
+		if len(problems) > 0 && !force {
+			return fmt.Errorf("refusing to delete %s (use --force)", wt.Name)
+		}
+
+		svc.HookLog = os.Stderr
+		result, err := svc.DeleteWorktree(wt.Path, opts)
+		if err != nil {
+			return err
+		}
+		if check.HasSession {
+			fmt.Printf("Killed session: %s\n", check.SessionName)
+		}
+		fmt.Printf("Removed worktree: %s\n", wt.Path)
+		if result.BranchDeleted {
+			fmt.Printf("Deleted branch: %s\n", result.Branch)
+		}
+		if result.DeletedRemote != "" {
+			fmt.Printf("Deleted remote branch: %s\n", result.DeletedRemote)
+		}
+		if result.KeptReason != "" {
+			fmt.Printf("Kept branch: %s\n", result.KeptReason)
+		}
+		return nil
+	},
+}
+
+func printBranchPlan(info *workspace.BranchInfo, opts workspace.DeleteOptions) {
+	switch {
+	case info.Name == "":
+		return
+	case info.Name == info.Base:
+		fmt.Printf("Would keep branch: %s is the base branch\n", info.Name)
+		return
+	case opts.Branch == workspace.DeleteMergedBranch && !info.Merged:
+		fmt.Printf("Would keep branch: %s is not merged into %s\n", info.Name, info.Base)
+		return
+	}
+	fmt.Printf("Would delete branch: %s\n", info.Name)
+	if opts.DeleteRemote && info.Remote != "" {
+		fmt.Printf("Would delete remote branch: %s/%s\n", info.Remote, info.RemoteBranch)
+	}
+}
+
+func init() {
+	rmCmd.Flags().BoolP("force", "f", false, "Delete even with uncommitted changes, unpushed commits or running processes")
+	rmCmd.Flags().Bool("keep-branch", true, "Keep the worktree's branch (default)")
+	rmCmd.Flags().Bool("delete-branch", false, "Also delete the worktree's branch if it is merged into the base branch")
+	rmCmd.Flags().Bool("force-delete-branch", false, "Also delete the worktree's branch even if it is not merged")
+	rmCmd.Flags().Bool("delete-remote", false, "Also delete the branch's upstream on its remote")
+	rmCmd.Flags().Bool("dry-run", false, "Show what would be deleted without deleting")
+	rmCmd.MarkFlagsMutuallyExclusive("keep-branch", "delete-branch", "force-delete-branch")
+	rootCmd.AddCommand(rmCmd)
+}
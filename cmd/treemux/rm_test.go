@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestRmFromLinkedWorktree(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	repo := filepath.Join(dir, "repo")
+	feat := filepath.Join(dir, "repo-feat")
+	runGit(t, dir, "init", "-q", "-b", "main", repo)
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "init")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feat", feat)
+	t.Chdir(feat)
+
+	err := rmCmd.RunE(rmCmd, []string{repo})
+	if err == nil || !strings.Contains(err.Error(), "main worktree") {
+		t.Fatalf("rm of the main worktree: got %v", err)
+	}
+	if _, err := os.Stat(repo); err != nil {
+		t.Fatalf("main worktree is gone: %v", err)
+	}
+
+	// The worktree rm runs from is not the main one.
+	if err := rmCmd.Flags().Set("dry-run", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rmCmd.Flags().Set("dry-run", "false") })
+	if err := rmCmd.RunE(rmCmd, []string{feat}); err != nil {
+		t.Fatalf("dry run of the linked worktree: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,11 +19,138 @@ const (
 )
 
 type Config struct {
-	BaseBranch  string   `mapstructure:"base_branch"`
-	PathPattern string   `mapstructure:"path_pattern"`
-	SessionName string   `mapstructure:"session_name"`
-	Theme       string   `mapstructure:"theme"`
-	SearchPaths []string `mapstructure:"search_paths"`
+	BaseBranch  string                `mapstructure:"base_branch"`
+	PathPattern string                `mapstructure:"path_pattern"`
+	SessionName string                `mapstructure:"session_name"`
+	Theme       string                `mapstructure:"theme"`
+	SearchPaths []string              `mapstructure:"search_paths"`
+	Scan        Scan                  `mapstructure:"scan"`
+	Layout      *Layout               `mapstructure:"layout"`
+	Hooks       Hooks                 `mapstructure:"hooks"`
+	Restore     Restore               `mapstructure:"restore"`
+	Repos       map[string]RepoConfig `mapstructure:"repos"`
+	// TrustedRepos lists the repository roots whose .treemux.yaml may set
+	// hooks and a layout, which run commands.
+	TrustedRepos []string `mapstructure:"trusted_repos"`
+
+	// sources maps the keys set by the user config to its path.
+	sources Sources
+}
+
+// Restore controls how saved sessions are rebuilt. Auto restores them when
+// treemux launches. Commands lists the programs restarted in restored panes
+// ("*" allows any); other panes get a shell in the saved directory.
+type Restore struct {
+	Auto     bool     `mapstructure:"auto"`
+	Commands []string `mapstructure:"commands"`
+}
+
+// Scan controls how repositories are found under SearchPaths. MaxDepth is
+// the number of directory levels searched below each path; Exclude holds
+// glob patterns for directories that are skipped, matched against the
+// directory name or, when the pattern contains a '/', the path relative to
+// the search path.
+type Scan struct {
+	MaxDepth       int      `mapstructure:"max_depth"`
+	Exclude        []string `mapstructure:"exclude"`
+	FollowSymlinks bool     `mapstructure:"follow_symlinks"`
+}
+
+const defaultScanDepth = 3
+
+var defaultScanExclude = []string{".*", "node_modules", "vendor"}
+
+var defaultRestoreCommands = []string{"vi", "vim", "nvim", "emacs", "nano", "man", "less", "more", "tail", "top", "htop", "btop", "lazygit"}
+
+// RepoConfig holds settings that apply to a single repository: an entry in
+// Config.Repos, keyed by repository name or absolute path, or the project's
+// .treemux.yaml. Empty fields leave the user config unchanged.
+type RepoConfig struct {
+	BaseBranch  string  `mapstructure:"base_branch"`
+	PathPattern string  `mapstructure:"path_pattern"`
+	SessionName string  `mapstructure:"session_name"`
+	Layout      *Layout `mapstructure:"layout"`
+	Hooks       Hooks   `mapstructure:"hooks"`
+}
+
+// ProjectFile is the per-project config at the repository root.
+const ProjectFile = ".treemux.yaml"
+
+// Sources records where each effective setting came from, keyed like the
+// config file ("base_branch", "hooks.post_create", "restore.auto").
+// Settings missing from the map have their default value.
+type Sources map[string]string
+
+const SourceDefault = "default"
+
+func (s Sources) Get(key string) string {
+	if src, ok := s[key]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+// Hooks lists shell commands run on worktree lifecycle events. Repo hooks
+// run after the global ones for the same event.
+type Hooks struct {
+	PostCreate []string `mapstructure:"post_create"`
+	PreDelete  []string `mapstructure:"pre_delete"`
+	PostDelete []string `mapstructure:"post_delete"`
+	PostAdopt  []string `mapstructure:"post_adopt"`
+	OnJump     []string `mapstructure:"on_jump"`
+}
+
+// Named returns the hook lists keyed by event name.
+func (h Hooks) Named() map[string][]string {
+	return map[string][]string{
+		"post_create": h.PostCreate,
+		"pre_delete":  h.PreDelete,
+		"post_delete": h.PostDelete,
+		"post_adopt":  h.PostAdopt,
+		"on_jump":     h.OnJump,
+	}
+}
+
+func (h Hooks) empty() bool {
+	for _, cmds := range h.Named() {
+		if len(cmds) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (h Hooks) merge(other Hooks) Hooks {
+	return Hooks{
+		PostCreate: append(append([]string{}, h.PostCreate...), other.PostCreate...),
+		PreDelete:  append(append([]string{}, h.PreDelete...), other.PreDelete...),
+		PostDelete: append(append([]string{}, h.PostDelete...), other.PostDelete...),
+		PostAdopt:  append(append([]string{}, h.PostAdopt...), other.PostAdopt...),
+		OnJump:     append(append([]string{}, h.OnJump...), other.OnJump...),
+	}
+}
+
+// Layout describes the windows and panes created for a new worktree session.
+type Layout struct {
+	Windows []LayoutWindow `mapstructure:"windows"`
+}
+
+type LayoutWindow struct {
+	Name    string       `mapstructure:"name"`
+	Dir     string       `mapstructure:"dir"`
+	Command string       `mapstructure:"command"`
+	Layout  string       `mapstructure:"layout"`
+	Panes   []LayoutPane `mapstructure:"panes"`
+}
+
+// LayoutPane is split off the window's first pane. Split is "horizontal"
+// (side by side) or "vertical" (stacked); Size is passed to tmux as-is,
+// e.g. "30%".
+type LayoutPane struct {
+	Dir     string `mapstructure:"dir"`
+	Command string `mapstructure:"command"`
+	Split   string `mapstructure:"split"`
+	Size    string `mapstructure:"size"`
 }
 
 func defaultConfig() *Config {
@@ -33,13 +161,185 @@ func defaultConfig() *Config {
 		SessionName: defaultSessionName,
 		Theme:       defaultTheme,
 		SearchPaths: []string{filepath.Join(home, "Documents", "development")},
+		Scan:        Scan{MaxDepth: defaultScanDepth, Exclude: defaultScanExclude},
+		Restore:     Restore{Commands: defaultRestoreCommands},
+	}
+}
+
+// UntrustedProjectError reports that the hooks and layout of a project file
+// were ignored because its repository is not in TrustedRepos.
+type UntrustedProjectError struct {
+	Path     string
+	RepoRoot string
+}
+
+func (e *UntrustedProjectError) Error() string {
+	return fmt.Sprintf("ignoring hooks and layout in %s: add %s to trusted_repos to run them", e.Path, e.RepoRoot)
+}
+
+// Trusted reports whether repoRoot is listed in TrustedRepos.
+func (c *Config) Trusted(repoRoot string) bool {
+	for _, root := range c.TrustedRepos {
+		if filepath.Clean(expandHome(root)) == filepath.Clean(repoRoot) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForRepo returns the effective config for repoRoot (see Resolve). A project
+// file that cannot be read is ignored.
+func (c *Config) ForRepo(repoRoot string) *Config {
+	out, _, _ := c.Resolve(repoRoot)
+	return out
+}
+
+// Resolve returns a copy of the config for repoRoot and the source of each
+// setting. Precedence, lowest first: defaults, the user config, the
+// project's .treemux.yaml, then the matching repos entry in the user config,
+// so a personal override always wins over the checked-in file. Hooks are not
+// replaced but run in that order. The project's hooks and layout are only
+// used when repoRoot is trusted; otherwise they are dropped and an
+// *UntrustedProjectError is returned along with the config.
+func (c *Config) Resolve(repoRoot string) (*Config, Sources, error) {
+	out := *c
+	sources := Sources{}
+	for k, v := range c.sources {
+		sources[k] = v
 	}
+	project, err := LoadProject(repoRoot)
+	if project != nil && !c.Trusted(repoRoot) && (project.Layout != nil || !project.Hooks.empty()) {
+		project.Layout = nil
+		project.Hooks = Hooks{}
+		err = &UntrustedProjectError{Path: filepath.Join(repoRoot, ProjectFile), RepoRoot: repoRoot}
+	}
+	if project != nil {
+		out.apply(*project, filepath.Join(repoRoot, ProjectFile), sources)
+	}
+	if key, rc, ok := c.repoConfig(repoRoot); ok {
+		out.apply(rc, c.sources.Get("repos")+" (repos."+key+")", sources)
+	}
+	return &out, sources, err
+}
+
+func (c *Config) apply(rc RepoConfig, source string, sources Sources) {
+	set := func(dst *string, val, key string) {
+		if val != "" {
+			*dst = val
+			sources[key] = source
+		}
+	}
+	set(&c.BaseBranch, rc.BaseBranch, "base_branch")
+	set(&c.PathPattern, rc.PathPattern, "path_pattern")
+	set(&c.SessionName, rc.SessionName, "session_name")
+	if rc.Layout != nil {
+		c.Layout = rc.Layout
+		sources["layout"] = source
+	}
+	for event, cmds := range rc.Hooks.Named() {
+		if len(cmds) == 0 {
+			continue
+		}
+		key := "hooks." + event
+		if prev, ok := sources[key]; ok {
+			sources[key] = prev + ", " + source
+		} else {
+			sources[key] = source
+		}
+	}
+	c.Hooks = c.Hooks.merge(rc.Hooks)
+}
+
+// LoadProject reads the .treemux.yaml at repoRoot. It returns nil without an
+// error when there is none.
+func LoadProject(repoRoot string) (*RepoConfig, error) {
+	if repoRoot == "" {
+		return nil, nil
+	}
+	path := filepath.Join(repoRoot, ProjectFile)
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	v := viper.NewWithOptions(viper.KeyDelimiter("::"))
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var rc RepoConfig
+	if err := v.Unmarshal(&rc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &rc, nil
+}
+
+func (c *Config) repoConfig(repoRoot string) (string, RepoConfig, bool) {
+	if repoRoot == "" || len(c.Repos) == 0 {
+		return "", RepoConfig{}, false
+	}
+	// viper lowercases map keys, so names and paths are matched case-insensitively.
+	name := repoName(repoRoot)
+	for key, rc := range c.Repos {
+		if strings.EqualFold(filepath.Clean(expandHome(key)), filepath.Clean(repoRoot)) {
+			return key, rc, true
+		}
+	}
+	for key, rc := range c.Repos {
+		if strings.EqualFold(key, name) {
+			return key, rc, true
+		}
+	}
+	return "", RepoConfig{}, false
+}
+
+// repoName is the repository name repos keys are matched against. It
+// follows git.RepoName, which config does not import: bare repositories
+// drop ".git" and the "project/.bare" layout is named after "project".
+func repoName(root string) string {
+	name := filepath.Base(root)
+	if name == ".bare" || name == ".git" {
+		name = filepath.Base(filepath.Dir(root))
+	}
+	if trimmed := strings.TrimSuffix(name, ".git"); trimmed != "" {
+		name = trimmed
+	}
+	return name
+}
+
+// Dir is the treemux config directory, which also holds state files such as
+// recent.json and hooks.log.
+func Dir() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "treemux")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "treemux")
+}
+
+// StateDir is the treemux state directory for files that can be rebuilt,
+// such as the repo index.
+func StateDir() string {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "treemux")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "treemux")
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, path[2:])
+	}
+	return path
 }
 
 func Load() (*Config, error) {
 	cfg := defaultConfig()
 
-	v := viper.New()
+	// Repos keys may be paths containing dots, so use a delimiter that
+	// cannot appear in them.
+	v := viper.NewWithOptions(viper.KeyDelimiter("::"))
 	v.SetConfigName("config")
 	v.AddConfigPath(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "treemux"))
 	v.AddConfigPath(filepath.Join(os.Getenv("HOME"), ".config", "treemux"))
@@ -54,6 +354,7 @@ func Load() (*Config, error) {
 		if err := v.Unmarshal(&cfg); err != nil {
 			return nil, err
 		}
+		cfg.sources = fileSources(v)
 		return cfg, nil
 	}
 
@@ -63,6 +364,7 @@ func Load() (*Config, error) {
 		if err := v.Unmarshal(&cfg); err != nil {
 			return nil, err
 		}
+		cfg.sources = fileSources(v)
 		return cfg, nil
 	}
 
@@ -74,6 +376,25 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// fileSources attributes every setting present in the file v read.
+func fileSources(v *viper.Viper) Sources {
+	file := v.ConfigFileUsed()
+	sources := Sources{}
+	for _, key := range v.AllKeys() {
+		if !v.InConfig(key) {
+			continue
+		}
+		parts := strings.Split(key, "::")
+		switch {
+		case (parts[0] == "hooks" || parts[0] == "restore" || parts[0] == "scan") && len(parts) > 1:
+			sources[parts[0]+"."+parts[1]] = file
+		default:
+			sources[parts[0]] = file
+		}
+	}
+	return sources
+}
+
 func loadLegacy() (*Config, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -87,6 +408,7 @@ func loadLegacy() (*Config, error) {
 	defer f.Close()
 
 	cfg := defaultConfig()
+	sources := Sources{}
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -105,10 +427,13 @@ func loadLegacy() (*Config, error) {
 		switch key {
 		case "TREEMUX_BASE_BRANCH":
 			cfg.BaseBranch = val
+			sources["base_branch"] = path
 		case "TREEMUX_PATH_PATTERN":
 			cfg.PathPattern = val
+			sources["path_pattern"] = path
 		case "TREEMUX_SESSION_NAME":
 			cfg.SessionName = val
+			sources["session_name"] = path
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -118,5 +443,6 @@ func loadLegacy() (*Config, error) {
 	if cfg.BaseBranch == defaultBaseBranch && cfg.PathPattern == defaultPathPattern && cfg.SessionName == defaultSessionName {
 		return nil, errors.New("no legacy keys")
 	}
+	cfg.sources = sources
 	return cfg, nil
 }
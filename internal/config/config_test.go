@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -40,3 +41,193 @@ theme: custom`)
 		t.Fatalf("theme mismatch: %s", cfg.Theme)
 	}
 }
+
+func TestLoadRepoLayout(t *testing.T) {
+	tmp := t.TempDir()
+	confDir := filepath.Join(tmp, "treemux")
+	if err := os.MkdirAll(confDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	content := []byte(`layout:
+  windows:
+    - name: shell
+repos:
+  api.service:
+    layout:
+      windows:
+        - name: editor
+          command: nvim
+        - name: dev
+          dir: web
+          panes:
+            - command: npm run dev
+              split: horizontal
+`)
+	if err := os.WriteFile(filepath.Join(confDir, "config.yaml"), content, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if got := cfg.ForRepo("/src/other").Layout; got == nil || len(got.Windows) != 1 || got.Windows[0].Name != "shell" {
+		t.Fatalf("global layout mismatch: %+v", got)
+	}
+	layout := cfg.ForRepo("/src/api.service").Layout
+	if layout == nil || len(layout.Windows) != 2 {
+		t.Fatalf("repo layout mismatch: %+v", layout)
+	}
+	dev := layout.Windows[1]
+	if dev.Dir != "web" || len(dev.Panes) != 1 || dev.Panes[0].Split != "horizontal" || dev.Panes[0].Command != "npm run dev" {
+		t.Fatalf("repo layout window mismatch: %+v", dev)
+	}
+}
+
+func TestLoadRestoreConfig(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	t.Setenv("HOME", tmp)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Restore.Auto || len(cfg.Restore.Commands) == 0 {
+		t.Fatalf("unexpected default restore config: %+v", cfg.Restore)
+	}
+
+	confDir := filepath.Join(tmp, "treemux")
+	if err := os.MkdirAll(confDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(confDir, "config.yaml"), []byte("restore:\n  auto: true\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Restore.Auto || len(cfg.Restore.Commands) == 0 {
+		t.Fatalf("expected auto restore with default commands: %+v", cfg.Restore)
+	}
+}
+
+func TestResolveProjectConfig(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	t.Setenv("HOME", tmp)
+	confDir := filepath.Join(tmp, "treemux")
+	if err := os.MkdirAll(confDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	userFile := filepath.Join(confDir, "config.yaml")
+	if err := os.WriteFile(userFile, []byte(`base_branch: develop
+hooks:
+  post_create:
+    - user-hook
+repos:
+  api:
+    session_name: branch
+trusted_repos:
+  - ~/api
+`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	repo := filepath.Join(tmp, "api")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	projectFile := filepath.Join(repo, ProjectFile)
+	if err := os.WriteFile(projectFile, []byte(`base_branch: trunk
+path_pattern: subdirectory
+session_name: folder
+layout:
+  windows:
+    - name: editor
+hooks:
+  post_create:
+    - project-hook
+`), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got, sources, err := cfg.Resolve(repo)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if got.BaseBranch != "trunk" || got.PathPattern != "subdirectory" || got.SessionName != "branch" {
+		t.Fatalf("unexpected effective config: %+v", got)
+	}
+	if got.Layout == nil || got.Layout.Windows[0].Name != "editor" {
+		t.Fatalf("project layout not applied: %+v", got.Layout)
+	}
+	if len(got.Hooks.PostCreate) != 2 || got.Hooks.PostCreate[0] != "user-hook" || got.Hooks.PostCreate[1] != "project-hook" {
+		t.Fatalf("unexpected hooks: %v", got.Hooks.PostCreate)
+	}
+	want := map[string]string{
+		"base_branch":       projectFile,
+		"path_pattern":      projectFile,
+		"session_name":      userFile + " (repos.api)",
+		"layout":            projectFile,
+		"hooks.post_create": userFile + ", " + projectFile,
+		"theme":             SourceDefault,
+	}
+	for key, src := range want {
+		if sources.Get(key) != src {
+			t.Errorf("source of %s = %q, want %q", key, sources.Get(key), src)
+		}
+	}
+
+	if other := cfg.ForRepo(filepath.Join(tmp, "web")); other.BaseBranch != "develop" || other.Layout != nil {
+		t.Fatalf("project config leaked into another repo: %+v", other)
+	}
+}
+
+func TestResolveUntrustedProject(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	t.Setenv("HOME", tmp)
+	repo := filepath.Join(tmp, "clone")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ProjectFile), []byte(`base_branch: trunk
+layout:
+  windows:
+    - name: editor
+      command: ./run-me
+hooks:
+  post_create:
+    - ./run-me
+`), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got, sources, err := cfg.Resolve(repo)
+	var untrusted *UntrustedProjectError
+	if !errors.As(err, &untrusted) || untrusted.RepoRoot != repo {
+		t.Fatalf("expected UntrustedProjectError, got %v", err)
+	}
+	if got.Layout != nil || len(got.Hooks.PostCreate) != 0 {
+		t.Fatalf("untrusted layout or hooks applied: %+v %v", got.Layout, got.Hooks)
+	}
+	if got.BaseBranch != "trunk" || sources.Get("layout") != SourceDefault {
+		t.Fatalf("unexpected effective config: %+v %v", got, sources)
+	}
+
+	cfg.TrustedRepos = []string{repo}
+	got, _, err = cfg.Resolve(repo)
+	if err != nil || got.Layout == nil || len(got.Hooks.PostCreate) != 1 {
+		t.Fatalf("trusted project not applied: %v %+v", err, got)
+	}
+}
@@ -0,0 +1,53 @@
+package git
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nicobailon/treemux/internal/shell"
+)
+
+// Causes of a failed git command, matched with errors.Is.
+var (
+	ErrBranchExists     = errors.New("branch already exists")
+	ErrBranchCheckedOut = errors.New("branch is checked out in another worktree")
+	ErrPathExists       = errors.New("path already exists")
+	ErrDirtyWorktree    = errors.New("worktree has uncommitted changes")
+	ErrWorktreeLocked   = errors.New("worktree is locked")
+)
+
+// CommandError is a git command that exited with an error. Its Cause is one
+// of the Err* values above, or nil.
+type CommandError = shell.CommandError
+
+// gitEnv runs git in the C locale so that classify can match its messages.
+var gitEnv = []string{"LC_ALL=C"}
+
+func newCommandError(args []string, out []byte, err error) *CommandError {
+	cmd := "git"
+	if len(args) > 0 {
+		cmd += " " + args[0]
+		if args[0] == "worktree" && len(args) > 1 {
+			cmd += " " + args[1]
+		}
+	}
+	e := shell.NewCommandError(cmd, args, out, err)
+	e.Cause = classify(e.Stderr)
+	return e
+}
+
+func classify(stderr string) error {
+	switch {
+	case strings.Contains(stderr, "a branch named") && strings.Contains(stderr, "already exists"):
+		return ErrBranchExists
+	case strings.Contains(stderr, "is already checked out at"), strings.Contains(stderr, "is already used by worktree at"):
+		return ErrBranchCheckedOut
+	case strings.Contains(stderr, "contains modified or untracked files"):
+		return ErrDirtyWorktree
+	case strings.Contains(stderr, "a locked working tree"):
+		return ErrWorktreeLocked
+	case strings.Contains(stderr, "already exists"):
+		return ErrPathExists
+	}
+	return nil
+}
@@ -2,10 +2,13 @@ package git
 
 import (
 	"errors"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nicobailon/treemux/internal/shell"
 )
@@ -23,29 +26,109 @@ func New(cmd shell.Commander) (*Git, error) {
 	return &Git{RepoRoot: root, Cmd: cmd}, nil
 }
 
+// repoRoot is the working tree containing the current directory, or the
+// git dir when it belongs to a bare repository.
 func repoRoot() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	out, err := cmd.Output()
+	out, err := exec.Command("git", "rev-parse", "--is-bare-repository", "--path-format=absolute", "--git-common-dir").Output()
+	if err != nil {
+		return "", errors.New("not in a git repository")
+	}
+	bare, common, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if bare == "true" {
+		return filepath.Clean(common), nil
+	}
+	out, err = exec.Command("git", "rev-parse", "--show-toplevel").Output()
 	if err != nil {
 		return "", errors.New("not in a git repository")
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
+// RootOf returns the root of the repository checked out at dir: its main
+// worktree, or the git dir of a bare repository, whose worktrees are all
+// linked ones. Submodules and checkouts with a separate git dir are their
+// own root.
+func RootOf(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--is-bare-repository", "--path-format=absolute", "--git-common-dir").Output()
+	if err != nil {
+		return "", errors.New("not in a git repository")
+	}
+	bare, common, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	common = filepath.Clean(common)
+	switch {
+	case bare == "true", IsBareDir(common):
+		return common, nil
+	case filepath.Base(common) == ".git":
+		return filepath.Dir(common), nil
+	}
+	out, err = exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", errors.New("not in a git repository")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsBareDir reports whether dir is the git dir of a bare repository. Only
+// directories laid out like a git dir are checked with git.
+func IsBareDir(dir string) bool {
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		return false
+	}
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	out, err := exec.Command("git", "config", "--file", filepath.Join(dir, "config"), "--bool", "core.bare").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// RepoName names the repository at root: the directory name, or for a bare
+// repository its name without ".git", taken from the parent directory for
+// the "project/.bare" layout.
+func RepoName(root string) string {
+	name := filepath.Base(root)
+	if name == ".bare" || name == ".git" {
+		name = filepath.Base(filepath.Dir(root))
+	}
+	if trimmed := strings.TrimSuffix(name, ".git"); trimmed != "" {
+		name = trimmed
+	}
+	return name
+}
+
+// IsBare reports whether RepoRoot is a bare repository rather than a
+// working tree.
+func (g *Git) IsBare() bool {
+	return IsBareDir(g.RepoRoot)
+}
+
 func (g *Git) run(args ...string) (string, error) {
-	out, err := g.Cmd.RunDir(g.RepoRoot, "git", args...)
+	return g.runDir(g.RepoRoot, args...)
+}
+
+// runDir runs git in dir. Failures are returned as a *CommandError.
+func (g *Git) runDir(dir string, args ...string) (string, error) {
+	out, err := g.Cmd.RunEnv(dir, gitEnv, "git", args...)
 	if err != nil {
-		return string(out), err
+		return string(out), newCommandError(args, out, err)
 	}
 	return string(out), nil
 }
 
 func (g *Git) DefaultBranch() string {
 	out, err := g.run("symbolic-ref", "refs/remotes/origin/HEAD")
-	if err != nil {
-		return "main"
+	if err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(out), "refs/remotes/origin/")
 	}
-	return strings.TrimPrefix(strings.TrimSpace(out), "refs/remotes/origin/")
+	// A bare clone has no remote-tracking branches; its HEAD is the
+	// remote's default branch.
+	if g.IsBare() {
+		if out, err := g.run("symbolic-ref", "--short", "HEAD"); err == nil {
+			return strings.TrimSpace(out)
+		}
+	}
+	return "main"
 }
 
 func (g *Git) Branches() ([]string, error) {
@@ -63,6 +146,24 @@ func (g *Git) Branches() ([]string, error) {
 	return branches, nil
 }
 
+// RemoteBranches lists remote-tracking branches such as origin/feature,
+// without the remotes' HEAD symrefs.
+func (g *Git) RemoteBranches() ([]string, error) {
+	out, err := g.run("for-each-ref", "--format=%(refname:short) %(symref)", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 1 {
+			continue
+		}
+		branches = append(branches, fields[0])
+	}
+	return branches, nil
+}
+
 func (g *Git) BranchExists(name string) bool {
 	_, err := g.run("show-ref", "--verify", "--quiet", "refs/heads/"+name)
 	return err == nil
@@ -72,31 +173,127 @@ type Worktree struct {
 	Path   string
 	Name   string
 	Branch string
+	// Head is the commit checked out in the worktree.
+	Head string
+	// Detached is set when HEAD is not on a branch; Branch is empty.
+	Detached bool
+	// Locked worktrees are skipped by prune and refused by remove and move.
+	// LockReason is the reason given to git worktree lock, if any.
+	Locked     bool
+	LockReason string
+	// Prunable worktrees have lost their directory; git worktree prune
+	// removes their administrative files. PrunableReason says why.
+	Prunable       bool
+	PrunableReason string
+}
+
+// GitDir returns the git dir of the worktree at path without running git:
+// linked worktrees have a .git file pointing at their gitdir.
+func GitDir(path string) string {
+	dotGit := filepath.Join(path, ".git")
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return dotGit
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return dotGit
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(path, gitDir)
+	}
+	return gitDir
+}
+
+// IndexPath returns the index file of the worktree at path.
+func IndexPath(path string) string {
+	return filepath.Join(GitDir(path), "index")
+}
+
+// CommonDir returns the git dir shared by all worktrees of the repository
+// of the worktree at path, which holds the refs.
+func CommonDir(path string) string {
+	gitDir := GitDir(path)
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common)
 }
 
+// MainWorktree returns the root of the repository's main worktree, which
+// differs from RepoRoot when treemux was started in a linked worktree. For
+// a bare repository it is the git dir.
+func (g *Git) MainWorktree() string {
+	out, err := g.run("rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return g.RepoRoot
+	}
+	common := filepath.Clean(strings.TrimSpace(out))
+	switch {
+	case filepath.Base(common) == ".git":
+		return filepath.Dir(common)
+	case IsBareDir(common):
+		return common
+	}
+	return g.RepoRoot
+}
+
+// WorktreeList lists the worktrees of the repository. The entry for a bare
+// repository's git dir is left out, as it has no working tree.
 func (g *Git) WorktreeList() ([]Worktree, error) {
 	out, err := g.run("worktree", "list", "--porcelain")
 	if err != nil {
 		return nil, err
 	}
+	return ParseWorktreeList(out), nil
+}
+
+// ParseWorktreeList parses the output of git worktree list --porcelain.
+// Records are separated by blank lines; attribute lines git adds in later
+// versions are ignored.
+func ParseWorktreeList(out string) []Worktree {
 	var wts []Worktree
 	var current Worktree
-	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
-		if strings.HasPrefix(line, "worktree ") {
-			if current.Path != "" {
-				wts = append(wts, current)
-			}
-			current = Worktree{Path: strings.TrimSpace(strings.TrimPrefix(line, "worktree "))}
-			current.Name = filepath.Base(current.Path)
-		} else if strings.HasPrefix(line, "branch ") {
-			branch := strings.TrimSpace(strings.TrimPrefix(line, "branch "))
-			current.Branch = strings.TrimPrefix(branch, "refs/heads/")
+	bare := false
+	flush := func() {
+		if current.Path != "" && !bare {
+			wts = append(wts, current)
 		}
+		current = Worktree{}
+		bare = false
 	}
-	if current.Path != "" {
-		wts = append(wts, current)
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(strings.TrimRight(line, "\r"), " ")
+		switch key {
+		case "":
+			flush()
+		case "worktree":
+			flush()
+			current.Path = value
+			current.Name = filepath.Base(value)
+		case "HEAD":
+			current.Head = value
+		case "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "detached":
+			current.Detached = true
+		case "bare":
+			bare = true
+		case "locked":
+			current.Locked = true
+			current.LockReason = value
+		case "prunable":
+			current.Prunable = true
+			current.PrunableReason = value
+		}
 	}
-	return wts, nil
+	flush()
+	return wts
 }
 
 func (g *Git) WorktreeAdd(path, branch, base string) error {
@@ -114,6 +311,55 @@ func (g *Git) WorktreeAdd(path, branch, base string) error {
 	return err
 }
 
+// WorktreeAddExisting checks out an existing local branch in a new worktree.
+func (g *Git) WorktreeAddExisting(path, branch string) error {
+	if !g.BranchExists(branch) {
+		return fmt.Errorf("branch %q does not exist", branch)
+	}
+	_, err := g.run("worktree", "add", path, branch)
+	return err
+}
+
+// WorktreeAddTracking creates branch tracking remoteBranch (e.g.
+// origin/feature) and checks it out in a new worktree. The remote branch is
+// fetched first if it is not known locally.
+func (g *Git) WorktreeAddTracking(path, branch, remoteBranch string) error {
+	if !g.RefExists("refs/remotes/" + remoteBranch) {
+		remote, name, ok := strings.Cut(remoteBranch, "/")
+		if !ok {
+			return fmt.Errorf("remote branch %q must look like <remote>/<branch>", remoteBranch)
+		}
+		if _, err := g.run("fetch", remote, "refs/heads/"+name+":refs/remotes/"+remoteBranch); err != nil {
+			return fmt.Errorf("fetch %s: %w", remoteBranch, err)
+		}
+	}
+	_, err := g.run("worktree", "add", "--track", "-b", branch, path, remoteBranch)
+	return err
+}
+
+// PullRequestRef turns "123" or "#123" into refs/pull/123/head; other refs
+// are returned unchanged.
+func PullRequestRef(arg string) string {
+	n := strings.TrimPrefix(arg, "#")
+	if _, err := strconv.Atoi(n); err == nil {
+		return "refs/pull/" + n + "/head"
+	}
+	return arg
+}
+
+// WorktreeAddRef fetches ref (e.g. refs/pull/123/head) from remote into a new
+// local branch and checks it out in a new worktree.
+func (g *Git) WorktreeAddRef(path, branch, remote, ref string) error {
+	if g.BranchExists(branch) {
+		return fmt.Errorf("branch %q already exists", branch)
+	}
+	if _, err := g.run("fetch", remote, ref+":refs/heads/"+branch); err != nil {
+		return fmt.Errorf("fetch %s from %s: %w", ref, remote, err)
+	}
+	_, err := g.run("worktree", "add", path, branch)
+	return err
+}
+
 func (g *Git) WorktreeRemove(path string, force bool) error {
 	args := []string{"worktree", "remove", path}
 	if force {
@@ -123,34 +369,294 @@ func (g *Git) WorktreeRemove(path string, force bool) error {
 	return err
 }
 
+// WorktreePrune drops the administrative files of worktrees whose directory
+// is gone.
+func (g *Git) WorktreePrune() error {
+	_, err := g.run("worktree", "prune")
+	return err
+}
+
+// WorktreeLock locks the worktree at path so it is not pruned, moved or
+// removed. reason may be empty.
+func (g *Git) WorktreeLock(path, reason string) error {
+	args := []string{"worktree", "lock"}
+	if reason != "" {
+		args = append(args, "--reason", reason)
+	}
+	_, err := g.run(append(args, path)...)
+	return err
+}
+
+func (g *Git) WorktreeUnlock(path string) error {
+	_, err := g.run("worktree", "unlock", path)
+	return err
+}
+
+// WorktreeRepair fixes the links between the repository and its worktrees,
+// e.g. after either was moved by hand. paths are worktrees to repair besides
+// the ones git can still find.
+func (g *Git) WorktreeRepair(paths ...string) error {
+	_, err := g.run(append([]string{"worktree", "repair"}, paths...)...)
+	return err
+}
+
+func (g *Git) WorktreeMove(from, to string) error {
+	_, err := g.run("worktree", "move", from, to)
+	return err
+}
+
+func (g *Git) RenameBranch(from, to string) error {
+	_, err := g.run("branch", "-m", from, to)
+	return err
+}
+
+func (g *Git) DeleteBranch(name string, force bool) error {
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+	_, err := g.run("branch", flag, name)
+	return err
+}
+
+// IsMerged reports whether branch is fully contained in into.
+func (g *Git) IsMerged(branch, into string) bool {
+	_, err := g.run("merge-base", "--is-ancestor", "refs/heads/"+branch, into)
+	return err == nil
+}
+
+// IsSquashMerged reports whether the changes branch made since it forked
+// from into were applied to into as a single commit, as a squash merge
+// does. A branch without changes is not reported.
+func (g *Git) IsSquashMerged(branch, into string) bool {
+	ref := "refs/heads/" + branch
+	base, err := g.run("merge-base", into, ref)
+	if err != nil {
+		return false
+	}
+	base = strings.TrimSpace(base)
+	trees, err := g.run("rev-parse", ref+"^{tree}", base+"^{tree}")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(trees)
+	if len(fields) != 2 || fields[0] == fields[1] {
+		return false
+	}
+	tree := fields[0]
+	// git cherry marks commits of into with the same patch as the squashed
+	// branch with "-".
+	squash, err := g.run("-c", "user.name=treemux", "-c", "user.email=treemux@localhost", "commit-tree", tree, "-p", base, "-m", "squash "+branch)
+	if err != nil {
+		return false
+	}
+	out, err := g.run("cherry", into, strings.TrimSpace(squash))
+	return err == nil && strings.HasPrefix(strings.TrimSpace(out), "-")
+}
+
+// Upstream returns the remote and remote branch name that branch tracks.
+func (g *Git) Upstream(branch string) (remote, remoteBranch string, ok bool) {
+	out, err := g.run("for-each-ref", "--format=%(upstream:remotename) %(upstream:remoteref)", "refs/heads/"+branch)
+	if err != nil {
+		return "", "", false
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], strings.TrimPrefix(fields[1], "refs/heads/"), true
+}
+
+// UpstreamGone reports whether branch tracks a remote branch that no longer
+// exists, e.g. because it was deleted after a merge.
+func (g *Git) UpstreamGone(branch string) bool {
+	out, err := g.run("for-each-ref", "--format=%(upstream:track)", "refs/heads/"+branch)
+	return err == nil && strings.TrimSpace(out) == "[gone]"
+}
+
+// LastCommitTime returns the committer date of HEAD in the worktree at path.
+func (g *Git) LastCommitTime(path string) time.Time {
+	out, err := g.Cmd.RunDir(path, "git", "log", "-1", "--format=%ct")
+	if err != nil {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+func (g *Git) DeleteRemoteBranch(remote, branch string) error {
+	_, err := g.run("push", remote, "--delete", branch)
+	return err
+}
+
+// ResolveRef returns the commit ref points to, or "" if it does not exist.
+func (g *Git) ResolveRef(ref string) string {
+	out, err := g.run("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// BranchMoved reports whether branch was updated after it was created,
+// according to its reflog.
+func (g *Git) BranchMoved(branch string) bool {
+	out, err := g.run("reflog", "show", "-n", "2", "--format=%H", "refs/heads/"+branch)
+	if err != nil {
+		return false
+	}
+	return len(strings.Fields(out)) > 1
+}
+
+func (g *Git) RefExists(ref string) bool {
+	_, err := g.run("rev-parse", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+func (g *Git) HasRemotes() bool {
+	out, err := g.run("remote")
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
 type StatusSummary struct {
-	Staged    int
-	Modified  int
-	Untracked int
-	Clean     bool
+	// Staged and Modified count files with changes in the index and in the
+	// working tree; a file with both counts in each.
+	Staged     int
+	Modified   int
+	Untracked  int
+	Conflicted int
+	// Renamed counts staged renames and copies, which are also in Staged.
+	Renamed int
+	Clean   bool
+	// Upstream is the tracked branch, e.g. origin/main, or "" without one.
+	// UpstreamGone is set when it no longer exists; Ahead and Behind are 0
+	// then.
+	Upstream     string
+	UpstreamGone bool
+	Ahead        int
+	Behind       int
+	// Stashes counts the repository's stash entries, which all worktrees
+	// share.
+	Stashes int
+	// Operation is the rebase, merge, cherry-pick, revert or bisect in
+	// progress in the worktree, or "".
+	Operation string
 }
 
+// Status summarizes the worktree at path with a single git status call.
 func (g *Git) Status(path string) (*StatusSummary, error) {
-	out, err := g.Cmd.RunDir(path, "git", "status", "--short")
+	out, err := g.runDir(path, "status", "--porcelain=v2", "--branch", "--show-stash", "-z")
 	if err != nil {
 		return nil, err
 	}
+	summary := ParseStatus(out)
+	summary.Operation = Operation(path)
+	return summary, nil
+}
+
+// ParseStatus parses the output of git status --porcelain=v2 --branch
+// --show-stash -z.
+func ParseStatus(out string) *StatusSummary {
 	summary := &StatusSummary{}
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		if strings.TrimSpace(line) == "" {
+	ab := false
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if header, ok := strings.CutPrefix(record, "# "); ok {
+			key, value, _ := strings.Cut(header, " ")
+			switch key {
+			case "branch.upstream":
+				summary.Upstream = value
+			case "branch.ab":
+				ab = true
+				fmt.Sscanf(value, "+%d -%d", &summary.Ahead, &summary.Behind)
+			case "stash":
+				summary.Stashes, _ = strconv.Atoi(value)
+			}
 			continue
 		}
-		switch {
-		case strings.HasPrefix(line, "??"):
+		kind, rest, _ := strings.Cut(record, " ")
+		switch kind {
+		case "1", "2":
+			if len(rest) < 2 {
+				continue
+			}
+			if rest[0] != '.' {
+				summary.Staged++
+			}
+			if rest[1] != '.' {
+				summary.Modified++
+			}
+			if kind == "2" {
+				summary.Renamed++
+				// the original path follows as its own record
+				i++
+			}
+		case "u":
+			summary.Conflicted++
+		case "?":
 			summary.Untracked++
-		case strings.HasPrefix(line, " M") || strings.HasPrefix(line, "MM") || strings.HasPrefix(line, "AM"):
-			summary.Modified++
-		default:
-			summary.Staged++
 		}
 	}
-	summary.Clean = summary.Staged == 0 && summary.Modified == 0 && summary.Untracked == 0
-	return summary, nil
+	summary.UpstreamGone = summary.Upstream != "" && !ab
+	summary.Clean = summary.Staged == 0 && summary.Modified == 0 && summary.Untracked == 0 && summary.Conflicted == 0
+	return summary
+}
+
+// Operation returns the rebase, merge, cherry-pick, revert or bisect in
+// progress in the worktree at path, or "", by looking for the state files
+// git leaves in the worktree's git dir.
+func Operation(path string) string {
+	gitDir := GitDir(path)
+	for _, op := range []struct{ file, name string }{
+		{"rebase-merge", "rebase"},
+		{"rebase-apply", "rebase"},
+		{"MERGE_HEAD", "merge"},
+		{"CHERRY_PICK_HEAD", "cherry-pick"},
+		{"REVERT_HEAD", "revert"},
+		{"BISECT_LOG", "bisect"},
+	} {
+		if _, err := os.Stat(filepath.Join(gitDir, op.file)); err == nil {
+			return op.name
+		}
+	}
+	return ""
+}
+
+// IgnoredDirs returns the directories of the worktree at path that are
+// excluded by .gitignore, relative to path and without a trailing slash.
+func (g *Git) IgnoredDirs(path string) ([]string, error) {
+	out, err := g.runDir(path, "ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range strings.Split(out, "\x00") {
+		if dir, ok := strings.CutSuffix(entry, "/"); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// Ignored returns the subset of files (relative to path) that .gitignore
+// excludes. Tracked files are never ignored; the files need not exist.
+func (g *Git) Ignored(path string, files []string) []string {
+	if len(files) == 0 {
+		return nil
+	}
+	// check-ignore exits 1 when nothing matches
+	out, _ := g.Cmd.RunDir(path, "git", append([]string{"check-ignore", "--"}, files...)...)
+	var ignored []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" && !strings.HasPrefix(line, "fatal:") {
+			ignored = append(ignored, line)
+		}
+	}
+	return ignored
 }
 
 type Commit struct {
@@ -159,12 +665,12 @@ type Commit struct {
 }
 
 func (g *Git) Log(path string, n int) ([]Commit, error) {
-	out, err := g.Cmd.RunDir(path, "git", "log", "--oneline", "-n", strconv.Itoa(n))
+	out, err := g.runDir(path, "log", "--oneline", "-n", strconv.Itoa(n))
 	if err != nil {
 		return nil, err
 	}
 	var commits []Commit
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
 		if line == "" {
 			continue
 		}
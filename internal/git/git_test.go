@@ -0,0 +1,294 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nicobailon/treemux/internal/shell"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestWorktreeFromRemoteBranchAndPullRequest(t *testing.T) {
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	seed := filepath.Join(dir, "seed")
+	repo := filepath.Join(dir, "repo")
+
+	runGit(t, dir, "init", "-q", "--bare", remote)
+	runGit(t, dir, "init", "-q", "-b", "main", seed)
+	runGit(t, seed, "commit", "-q", "--allow-empty", "-m", "init")
+	runGit(t, seed, "push", "-q", remote, "main")
+	runGit(t, seed, "checkout", "-q", "-b", "colleague")
+	runGit(t, seed, "commit", "-q", "--allow-empty", "-m", "colleague work")
+	runGit(t, seed, "push", "-q", remote, "colleague")
+	runGit(t, seed, "checkout", "-q", "-b", "contrib", "main")
+	runGit(t, seed, "commit", "-q", "--allow-empty", "-m", "pr work")
+	prCommit := runGit(t, seed, "rev-parse", "HEAD")
+	runGit(t, seed, "push", "-q", remote, "HEAD:refs/pull/7/head")
+	runGit(t, dir, "clone", "-q", remote, repo)
+
+	g := &Git{RepoRoot: repo, Cmd: &shell.ExecCommander{}}
+	remotes, err := g.RemoteBranches()
+	if err != nil {
+		t.Fatalf("remote branches: %v", err)
+	}
+	if strings.Join(remotes, ",") != "origin/colleague,origin/main" {
+		t.Fatalf("unexpected remote branches: %v", remotes)
+	}
+
+	wt := filepath.Join(dir, "repo-colleague")
+	if err := g.WorktreeAddTracking(wt, "colleague", "origin/colleague"); err != nil {
+		t.Fatalf("add tracking worktree: %v", err)
+	}
+	if remote, branch, ok := g.Upstream("colleague"); !ok || remote != "origin" || branch != "colleague" {
+		t.Fatalf("colleague does not track origin/colleague: %s %s %v", remote, branch, ok)
+	}
+	if got := runGit(t, wt, "log", "-1", "--format=%s"); got != "colleague work" {
+		t.Fatalf("unexpected HEAD in tracking worktree: %q", got)
+	}
+
+	if ref := PullRequestRef("#7"); ref != "refs/pull/7/head" {
+		t.Fatalf("unexpected pull request ref: %s", ref)
+	}
+	prPath := filepath.Join(dir, "repo-pr-7")
+	if err := g.WorktreeAddRef(prPath, "pr-7", "origin", PullRequestRef("7")); err != nil {
+		t.Fatalf("add pull request worktree: %v", err)
+	}
+	if got := runGit(t, prPath, "rev-parse", "HEAD"); got != prCommit {
+		t.Fatalf("pull request worktree at %s, want %s", got, prCommit)
+	}
+	if err := g.WorktreeAddRef(filepath.Join(dir, "again"), "pr-7", "origin", "refs/pull/7/head"); err == nil {
+		t.Fatalf("expected an error for an existing branch")
+	}
+}
+
+func TestCommandErrorCauses(t *testing.T) {
+	// Causes are matched on git's English messages whatever the locale.
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	runGit(t, dir, "init", "-q", "-b", "main", repo)
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "init")
+	g := &Git{RepoRoot: repo, Cmd: &shell.ExecCommander{}}
+
+	feat := filepath.Join(dir, "feat")
+	if err := g.WorktreeAdd(feat, "feat", "main"); err != nil {
+		t.Fatalf("add worktree: %v", err)
+	}
+	err := g.RenameBranch("feat", "main")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !errors.Is(err, ErrBranchExists) || cmdErr.ExitCode != 128 {
+		t.Fatalf("expected ErrBranchExists, got %v", err)
+	}
+	if err.Error() != "git branch: a branch named 'main' already exists" {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+	if err := g.WorktreeAdd(feat, "other", "main"); !errors.Is(err, ErrPathExists) {
+		t.Fatalf("expected ErrPathExists, got %v", err)
+	}
+	if err := g.WorktreeAddExisting(filepath.Join(dir, "again"), "feat"); !errors.Is(err, ErrBranchCheckedOut) {
+		t.Fatalf("expected ErrBranchCheckedOut, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(feat, "new.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WorktreeRemove(feat, false); !errors.Is(err, ErrDirtyWorktree) {
+		t.Fatalf("expected ErrDirtyWorktree, got %v", err)
+	}
+}
+
+func TestBareRepository(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	seed := filepath.Join(dir, "seed")
+	runGit(t, dir, "init", "-q", "-b", "trunk", seed)
+	runGit(t, seed, "commit", "-q", "--allow-empty", "-m", "init")
+	project := filepath.Join(dir, "proj")
+	bare := filepath.Join(project, ".bare")
+	runGit(t, dir, "clone", "-q", "--bare", seed, bare)
+	if err := os.WriteFile(filepath.Join(project, ".git"), []byte("gitdir: ./.bare\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, project, "worktree", "add", "-q", "trunk", "trunk")
+
+	if !IsBareDir(bare) || IsBareDir(seed) || IsBareDir(filepath.Join(seed, ".git")) {
+		t.Fatal("unexpected bare detection")
+	}
+	for _, start := range []string{bare, project, filepath.Join(project, "trunk")} {
+		if root, err := RootOf(start); err != nil || root != bare {
+			t.Errorf("RootOf(%s) = %s, %v; want %s", start, root, err, bare)
+		}
+	}
+	if root, err := RootOf(seed); err != nil || root != seed {
+		t.Errorf("RootOf(%s) = %s, %v", seed, root, err)
+	}
+	if RepoName(bare) != "proj" || RepoName("/src/api.git") != "api" || RepoName("/src/web") != "web" {
+		t.Fatal("unexpected repo names")
+	}
+
+	g := &Git{RepoRoot: bare, Cmd: &shell.ExecCommander{}}
+	if g.MainWorktree() != bare || g.DefaultBranch() != "trunk" {
+		t.Fatalf("unexpected main worktree %s or default branch %s", g.MainWorktree(), g.DefaultBranch())
+	}
+	runGit(t, project, "worktree", "add", "-q", "--detach", "detached")
+	wts, err := g.WorktreeList()
+	if err != nil || len(wts) != 2 {
+		t.Fatalf("expected the bare entry to be hidden: %+v %v", wts, err)
+	}
+	if wts[0].Name != "detached" || !wts[0].Detached || wts[1].Branch != "trunk" {
+		t.Fatalf("unexpected worktrees: %+v", wts)
+	}
+}
+
+func TestParseWorktreeList(t *testing.T) {
+	out := `worktree /src/app/.bare
+bare
+
+worktree /src/app/main
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /src/app/review
+HEAD 2222222222222222222222222222222222222222
+detached
+locked on a usb drive
+
+worktree /src/app/gone
+HEAD 3333333333333333333333333333333333333333
+branch refs/heads/feat/gone
+locked
+prunable gitdir file points to non-existent location
+`
+	wts := ParseWorktreeList(out)
+	if len(wts) != 3 {
+		t.Fatalf("expected 3 worktrees: %+v", wts)
+	}
+	if wts[0].Name != "main" || wts[0].Branch != "main" || wts[0].Head[:4] != "1111" || wts[0].Locked || wts[0].Prunable {
+		t.Errorf("unexpected main worktree: %+v", wts[0])
+	}
+	if !wts[1].Detached || wts[1].Branch != "" || !wts[1].Locked || wts[1].LockReason != "on a usb drive" {
+		t.Errorf("unexpected detached worktree: %+v", wts[1])
+	}
+	if wts[2].Branch != "feat/gone" || !wts[2].Locked || wts[2].LockReason != "" || !wts[2].Prunable || wts[2].PrunableReason != "gitdir file points to non-existent location" {
+		t.Errorf("unexpected prunable worktree: %+v", wts[2])
+	}
+}
+
+func TestWorktreeLockAndPrune(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	repo := filepath.Join(dir, "repo")
+	runGit(t, dir, "init", "-q", "-b", "main", repo)
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "init")
+	g := &Git{RepoRoot: repo, Cmd: &shell.ExecCommander{}}
+	feat := filepath.Join(dir, "feat")
+	if err := g.WorktreeAdd(feat, "feat", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WorktreeLock(feat, "in use"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WorktreeRemove(feat, true); !errors.Is(err, ErrWorktreeLocked) {
+		t.Fatalf("expected ErrWorktreeLocked, got %v", err)
+	}
+	if err := g.WorktreeUnlock(feat); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(feat); err != nil {
+		t.Fatal(err)
+	}
+	wts, _ := g.WorktreeList()
+	if len(wts) != 2 || wts[1].Locked || !wts[1].Prunable {
+		t.Fatalf("expected a prunable worktree: %+v", wts)
+	}
+	if err := g.WorktreePrune(); err != nil {
+		t.Fatal(err)
+	}
+	if wts, _ := g.WorktreeList(); len(wts) != 1 {
+		t.Fatalf("expected the worktree to be pruned: %+v", wts)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	out := strings.Join([]string{
+		"# branch.oid 1111111111111111111111111111111111111111",
+		"# branch.head feat",
+		"# branch.upstream origin/feat",
+		"# branch.ab +2 -1",
+		"# stash 3",
+		"1 MM N... 100644 100644 100644 aaaa bbbb both changed.go",
+		"1 .M N... 100644 100644 100644 aaaa aaaa with space.go",
+		"2 R. N... 100644 100644 100644 aaaa aaaa R100 new.go",
+		"old.go",
+		"u UU N... 100644 100644 100644 100644 aaaa bbbb cccc conflict.go",
+		"? untracked.go",
+		"",
+	}, "\x00")
+	s := ParseStatus(out)
+	want := StatusSummary{Staged: 2, Modified: 2, Untracked: 1, Conflicted: 1, Renamed: 1, Upstream: "origin/feat", Ahead: 2, Behind: 1, Stashes: 3}
+	if *s != want {
+		t.Fatalf("got %+v, want %+v", *s, want)
+	}
+
+	s = ParseStatus("# branch.oid 1111\x00# branch.head feat\x00# branch.upstream origin/gone\x00")
+	if !s.Clean || !s.UpstreamGone || s.Ahead != 0 {
+		t.Fatalf("expected a clean worktree with a gone upstream: %+v", *s)
+	}
+}
+
+func TestStatusDuringMerge(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	remote := filepath.Join(dir, "remote.git")
+	repo := filepath.Join(dir, "repo")
+	runGit(t, dir, "init", "-q", "--bare", remote)
+	runGit(t, dir, "init", "-q", "-b", "main", repo)
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "base\n")
+	runGit(t, repo, "add", "a.txt")
+	runGit(t, repo, "commit", "-q", "-m", "base")
+	runGit(t, repo, "remote", "add", "origin", remote)
+	runGit(t, repo, "push", "-q", "-u", "origin", "main")
+	runGit(t, repo, "checkout", "-q", "-b", "other")
+	write("a.txt", "other\n")
+	runGit(t, repo, "commit", "-q", "-am", "other")
+	runGit(t, repo, "checkout", "-q", "main")
+	write("a.txt", "main\n")
+	runGit(t, repo, "commit", "-q", "-am", "main")
+	write("a.txt", "stashed\n")
+	runGit(t, repo, "stash", "-q")
+	cmd := exec.Command("git", "merge", "-q", "other")
+	cmd.Dir = repo
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected a merge conflict")
+	}
+
+	g := &Git{RepoRoot: repo, Cmd: &shell.ExecCommander{}}
+	s, err := g.Status(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Clean || s.Conflicted != 1 || s.Operation != "merge" || s.Stashes != 1 || s.Upstream != "origin/main" || s.Ahead != 1 || s.Behind != 0 {
+		t.Fatalf("unexpected status: %+v", *s)
+	}
+	runGit(t, repo, "merge", "--abort")
+	if s, _ := g.Status(repo); !s.Clean || s.Operation != "" {
+		t.Fatalf("expected a clean worktree after the abort: %+v", *s)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"sort"
 	"time"
+
+	"github.com/nicobailon/treemux/internal/git"
 )
 
 const maxRecent = 10
@@ -68,7 +70,7 @@ func (s *Store) Save() error {
 }
 
 func (s *Store) Add(repoRoot, worktree, sessionName, wtPath string) {
-	repoName := filepath.Base(repoRoot)
+	repoName := git.RepoName(repoRoot)
 
 	for i, e := range s.Entries {
 		if e.RepoRoot == repoRoot && e.Worktree == worktree {
@@ -120,6 +122,21 @@ func (s *Store) GetOtherProjects(currentRepoRoot string, limit int) []Entry {
 	return others
 }
 
+// Move points the entries for the worktree at oldPath to its new path, name
+// and session. It reports whether any entry changed.
+func (s *Store) Move(oldPath, newPath, worktree, sessionName string) bool {
+	moved := false
+	for i, e := range s.Entries {
+		if e.Path == oldPath {
+			s.Entries[i].Path = newPath
+			s.Entries[i].Worktree = worktree
+			s.Entries[i].SessionName = sessionName
+			moved = true
+		}
+	}
+	return moved
+}
+
 func (s *Store) Remove(repoRoot, worktree string) {
 	var filtered []Entry
 	for _, e := range s.Entries {
@@ -0,0 +1,201 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/config"
+	"github.com/nicobailon/treemux/internal/git"
+)
+
+// Index is the result of a scan kept in the treemux config dir, so global
+// mode can list worktrees without walking the search paths again. Repos
+// whose git metadata changed since they were indexed are re-listed by
+// Refresh; repositories added under the search paths only show up after
+// Rebuild.
+type Index struct {
+	// Key identifies the search paths and options the index was built
+	// with; a different config needs a rebuild.
+	Key       string        `json:"key"`
+	ScannedAt time.Time     `json:"scanned_at"`
+	Repos     []IndexedRepo `json:"repos"`
+	path      string
+}
+
+type IndexedRepo struct {
+	Root string `json:"root"`
+	// Modified is the newest mtime of the git dir, its worktrees directory
+	// and the entries in it when the worktrees were listed.
+	Modified  time.Time         `json:"modified"`
+	Worktrees []IndexedWorktree `json:"worktrees"`
+}
+
+// IndexedWorktree has the fields of git.Worktree, so the two convert
+// directly.
+type IndexedWorktree struct {
+	Path           string `json:"path"`
+	Name           string `json:"name"`
+	Branch         string `json:"branch"`
+	Head           string `json:"head,omitempty"`
+	Detached       bool   `json:"detached,omitempty"`
+	Locked         bool   `json:"locked,omitempty"`
+	LockReason     string `json:"lock_reason,omitempty"`
+	Prunable       bool   `json:"prunable,omitempty"`
+	PrunableReason string `json:"prunable_reason,omitempty"`
+}
+
+// OptionsFor returns the scan options set by the scan config section.
+func OptionsFor(scan config.Scan) Options {
+	return Options{MaxDepth: scan.MaxDepth, Exclude: scan.Exclude, FollowSymlinks: scan.FollowSymlinks}
+}
+
+func IndexPath() string {
+	return filepath.Join(config.StateDir(), "index.json")
+}
+
+// LoadIndex reads the saved index. A missing or unreadable file gives an
+// empty index that matches no config.
+func LoadIndex() (*Index, error) {
+	ix := &Index{path: IndexPath()}
+	data, err := os.ReadFile(ix.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ix, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, ix); err != nil {
+		return &Index{path: ix.path}, nil
+	}
+	return ix, nil
+}
+
+func (ix *Index) Save() error {
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(ix, "", "  ")
+	if err != nil {
+		return err
+	}
+	// A unique temp file keeps concurrent saves, e.g. from two treemux
+	// instances, from writing into each other's file before the rename.
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), filepath.Base(ix.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ix.path)
+}
+
+func indexKey(searchPaths []string, opts Options) string {
+	return fmt.Sprintf("%q depth=%d exclude=%q symlinks=%v", searchPaths, opts.MaxDepth, opts.Exclude, opts.FollowSymlinks)
+}
+
+// Matches reports whether the index was built for searchPaths and opts.
+func (ix *Index) Matches(searchPaths []string, opts Options) bool {
+	return !ix.ScannedAt.IsZero() && ix.Key == indexKey(searchPaths, opts)
+}
+
+// Rebuild scans searchPaths from scratch.
+func (ix *Index) Rebuild(searchPaths []string, opts Options) {
+	ix.Key = indexKey(searchPaths, opts)
+	ix.ScannedAt = time.Now()
+	ix.Repos = nil
+	for _, root := range ScanForRepos(searchPaths, opts) {
+		ix.Repos = append(ix.Repos, indexRepo(root))
+	}
+}
+
+// Refresh re-lists the worktrees of repos whose git metadata changed and
+// drops repos that are gone. It reports whether the index changed.
+func (ix *Index) Refresh() bool {
+	changed := false
+	repos := ix.Repos[:0]
+	for _, repo := range ix.Repos {
+		if _, err := os.Stat(repo.Root); err != nil {
+			changed = true
+			continue
+		}
+		if !gitModified(repo.Root).Equal(repo.Modified) {
+			repo = indexRepo(repo.Root)
+			changed = true
+		}
+		repos = append(repos, repo)
+	}
+	ix.Repos = repos
+	return changed
+}
+
+// Worktrees lists the indexed worktrees like ScanAll.
+func (ix *Index) Worktrees() []RepoWorktree {
+	var all []RepoWorktree
+	for _, repo := range ix.Repos {
+		for _, wt := range repo.Worktrees {
+			all = append(all, RepoWorktree{
+				RepoName: git.RepoName(repo.Root),
+				RepoRoot: repo.Root,
+				Worktree: git.Worktree(wt),
+			})
+		}
+	}
+	return all
+}
+
+func indexRepo(root string) IndexedRepo {
+	// Read the mtime first, so a change made while listing is seen by the
+	// next Refresh.
+	repo := IndexedRepo{Root: root, Modified: gitModified(root)}
+	for _, wt := range GetWorktreesForRepo(root) {
+		repo.Worktrees = append(repo.Worktrees, IndexedWorktree(wt))
+	}
+	return repo
+}
+
+// gitModified returns the newest mtime of the repository's git dir, its
+// worktrees directory and each linked worktree's admin directory. Adding or
+// removing a worktree and switching branches in any worktree (HEAD is
+// replaced through a lock file) all update one of them.
+func gitModified(root string) time.Time {
+	gitDir := filepath.Join(root, ".git")
+	if _, err := os.Lstat(gitDir); err != nil {
+		// a bare repository is its own git dir
+		gitDir = root
+	} else if data, err := os.ReadFile(gitDir); err == nil {
+		// submodules and checkouts with a separate git dir
+		if dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: "); ok {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			gitDir = dir
+		}
+	}
+	worktrees := filepath.Join(gitDir, "worktrees")
+	dirs := []string{gitDir, worktrees}
+	if entries, err := os.ReadDir(worktrees); err == nil {
+		for _, e := range entries {
+			dirs = append(dirs, filepath.Join(worktrees, e.Name()))
+		}
+	}
+	var newest time.Time
+	for _, dir := range dirs {
+		if fi, err := os.Stat(dir); err == nil && fi.ModTime().After(newest) {
+			newest = fi.ModTime()
+		}
+	}
+	return newest
+}
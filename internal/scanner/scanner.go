@@ -1,102 +1,178 @@
 package scanner
 
 import (
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/shell"
 )
 
 type RepoWorktree struct {
 	RepoName string
 	RepoRoot string
 	Worktree git.Worktree
+	// SessionName is filled in by the caller, which knows each repo's
+	// session_name setting.
+	SessionName string
 }
 
-func ScanForRepos(searchPaths []string) []string {
-	var repos []string
-	seen := make(map[string]bool)
+// Options controls the walk below each search path.
+type Options struct {
+	// MaxDepth is the number of directory levels searched below a search
+	// path; repositories directly inside it are at depth 1.
+	MaxDepth int
+	// Exclude holds glob patterns for directories that are not searched.
+	// A pattern matches the directory name, or the path relative to the
+	// search path when it contains a '/'.
+	Exclude        []string
+	FollowSymlinks bool
+}
 
-	for _, searchPath := range searchPaths {
+// maxWalkers bounds the directories read and repositories inspected at once.
+var maxWalkers = min(runtime.NumCPU(), 8)
+
+// ScanForRepos returns the main worktree of every repository found below
+// searchPaths, or the git dir of a bare repository. A directory holding a
+// .git directory or file, or a bare repository, is not searched further;
+// linked worktrees and other checkouts of the same repository are reported
+// once.
+func ScanForRepos(searchPaths []string, opts Options) []string {
+	w := &walker{
+		opts:    opts,
+		sem:     make(chan struct{}, max(maxWalkers, 1)),
+		visited: map[visit]bool{},
+		roots:   make([][]string, len(searchPaths)),
+	}
+	for i, searchPath := range searchPaths {
 		expanded := os.ExpandEnv(searchPath)
 		if strings.HasPrefix(expanded, "~/") {
 			home, _ := os.UserHomeDir()
 			expanded = filepath.Join(home, expanded[2:])
 		}
+		w.wg.Add(1)
+		go w.visit(i, expanded, expanded, 0)
+	}
+	w.wg.Wait()
 
-		entries, err := os.ReadDir(expanded)
-		if err != nil {
-			continue
-		}
-
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
-			}
-			dirPath := filepath.Join(expanded, entry.Name())
-			gitPath := filepath.Join(dirPath, ".git")
-
-			if info, err := os.Stat(gitPath); err == nil {
-				if info.IsDir() {
-					repoRoot := findRepoRoot(dirPath)
-					if repoRoot != "" && !seen[repoRoot] {
-						repos = append(repos, repoRoot)
-						seen[repoRoot] = true
-					}
-				}
+	var repos []string
+	seen := make(map[string]bool)
+	for _, roots := range w.roots {
+		sort.Strings(roots)
+		for _, root := range roots {
+			if !seen[root] {
+				repos = append(repos, root)
+				seen[root] = true
 			}
 		}
 	}
-
 	return repos
 }
 
-func findRepoRoot(path string) string {
-	cmd := exec.Command("git", "-C", path, "rev-parse", "--show-toplevel")
-	out, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(out))
+type visit struct {
+	idx  int
+	path string
 }
 
-func GetWorktreesForRepo(repoRoot string) []git.Worktree {
-	cmd := exec.Command("git", "-C", repoRoot, "worktree", "list", "--porcelain")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil
-	}
+type walker struct {
+	opts Options
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	mu sync.Mutex
+	// visited holds the real path of every directory read below each
+	// search path, so symlinks cannot lead the walk in circles.
+	visited map[visit]bool
+	// roots holds the repositories found below each search path.
+	roots [][]string
+}
 
-	var worktrees []git.Worktree
-	var current git.Worktree
+func (w *walker) visit(idx int, base, dir string, depth int) {
+	defer w.wg.Done()
+	w.sem <- struct{}{}
+	defer func() { <-w.sem }()
 
-	for _, line := range strings.Split(string(out), "\n") {
-		if strings.HasPrefix(line, "worktree ") {
-			if current.Path != "" {
-				worktrees = append(worktrees, current)
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil || git.IsBareDir(dir) {
+		if root, err := git.RootOf(dir); err == nil {
+			w.mu.Lock()
+			w.roots[idx] = append(w.roots[idx], root)
+			w.mu.Unlock()
+		}
+		return
+	}
+	if depth >= w.opts.MaxDepth {
+		return
+	}
+	if w.opts.FollowSymlinks {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return
+		}
+		w.mu.Lock()
+		seen := w.visited[visit{idx, resolved}]
+		w.visited[visit{idx, resolved}] = true
+		w.mu.Unlock()
+		if seen {
+			return
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir():
+		case entry.Type()&fs.ModeSymlink != 0 && w.opts.FollowSymlinks:
+			if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+				continue
 			}
-			current = git.Worktree{Path: strings.TrimPrefix(line, "worktree ")}
-			current.Name = filepath.Base(current.Path)
-		} else if strings.HasPrefix(line, "branch ") {
-			branch := strings.TrimPrefix(line, "branch refs/heads/")
-			current.Branch = branch
+		default:
+			continue
+		}
+		if w.excluded(base, path) {
+			continue
 		}
+		w.wg.Add(1)
+		go w.visit(idx, base, path, depth+1)
 	}
-	if current.Path != "" {
-		worktrees = append(worktrees, current)
+}
+
+func (w *walker) excluded(base, path string) bool {
+	name := filepath.Base(path)
+	rel, _ := filepath.Rel(base, path)
+	for _, pattern := range w.opts.Exclude {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = filepath.ToSlash(rel)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
 	}
+	return false
+}
 
+// GetWorktreesForRepo lists the worktrees of the repository at repoRoot,
+// leaving out a bare repository's git dir.
+func GetWorktreesForRepo(repoRoot string) []git.Worktree {
+	g := &git.Git{RepoRoot: repoRoot, Cmd: &shell.ExecCommander{}}
+	worktrees, _ := g.WorktreeList()
 	return worktrees
 }
 
-func ScanAll(searchPaths []string) []RepoWorktree {
+func ScanAll(searchPaths []string, opts Options) []RepoWorktree {
 	var all []RepoWorktree
 
-	repos := ScanForRepos(searchPaths)
+	repos := ScanForRepos(searchPaths, opts)
 	for _, repoRoot := range repos {
-		repoName := filepath.Base(repoRoot)
+		repoName := git.RepoName(repoRoot)
 		worktrees := GetWorktreesForRepo(repoRoot)
 		for _, wt := range worktrees {
 			all = append(all, RepoWorktree{
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestScanForRepos(t *testing.T) {
+	dir := t.TempDir()
+	newRepo := func(path string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, dir, "init", "-q", "-b", "main", path)
+		runGit(t, path, "commit", "-q", "--allow-empty", "-m", "init")
+	}
+	dev := filepath.Join(dir, "dev")
+	newRepo(filepath.Join(dev, "app"))
+	newRepo(filepath.Join(dev, "org", "api"))
+	newRepo(filepath.Join(dev, "org", "team", "deep"))
+	newRepo(filepath.Join(dev, "web", "node_modules", "dep"))
+	// A linked worktree is reported as its main repository.
+	runGit(t, filepath.Join(dev, "app"), "worktree", "add", "-q", "-b", "feat", filepath.Join(dev, "app-feat"))
+	if err := os.Symlink(filepath.Join(dir, "elsewhere"), filepath.Join(dev, "link")); err != nil {
+		t.Fatal(err)
+	}
+	newRepo(filepath.Join(dir, "elsewhere", "linked"))
+
+	opts := Options{MaxDepth: 2, Exclude: []string{"node_modules"}}
+	// git reports resolved paths, and the temp dir may be behind a symlink.
+	root, _ := filepath.EvalSymlinks(dir)
+	rel := func(repos []string) string {
+		var names []string
+		for _, repo := range repos {
+			r, _ := filepath.Rel(root, repo)
+			names = append(names, filepath.ToSlash(r))
+		}
+		return strings.Join(names, ",")
+	}
+	if got := rel(ScanForRepos([]string{dev}, opts)); got != "dev/app,dev/org/api" {
+		t.Fatalf("unexpected repos: %s", got)
+	}
+	opts.MaxDepth = 3
+	opts.FollowSymlinks = true
+	if got := rel(ScanForRepos([]string{dev}, opts)); got != "dev/app,dev/org/api,dev/org/team/deep,elsewhere/linked" {
+		t.Fatalf("unexpected repos: %s", got)
+	}
+	opts.Exclude = []string{"org/team"}
+	if got := rel(ScanForRepos([]string{dev, filepath.Join(dev, "org")}, opts)); got != "dev/app,dev/org/api,dev/web/node_modules/dep,elsewhere/linked,dev/org/team/deep" {
+		t.Fatalf("unexpected repos: %s", got)
+	}
+}
+
+func TestIndexRefresh(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	app := filepath.Join(dir, "app")
+	runGit(t, dir, "init", "-q", "-b", "main", app)
+	runGit(t, app, "commit", "-q", "--allow-empty", "-m", "init")
+	lib := filepath.Join(dir, "lib")
+	runGit(t, dir, "init", "-q", "-b", "main", lib)
+
+	opts := Options{MaxDepth: 1}
+	ix, err := LoadIndex()
+	if err != nil || ix.Matches([]string{dir}, opts) {
+		t.Fatalf("expected an empty index: %+v %v", ix, err)
+	}
+	ix.Rebuild([]string{dir}, opts)
+	if err := ix.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if tmps, _ := filepath.Glob(ix.path + ".*.tmp"); len(tmps) > 0 {
+		t.Fatalf("temp files left behind: %v", tmps)
+	}
+	if ix, _ = LoadIndex(); !ix.Matches([]string{dir}, opts) || len(ix.Worktrees()) != 2 {
+		t.Fatalf("unexpected index: %+v", ix)
+	}
+	if ix.Refresh() {
+		t.Fatal("refresh changed an up-to-date index")
+	}
+
+	runGit(t, app, "worktree", "add", "-q", "-b", "feat", filepath.Join(dir, "app-feat"))
+	if err := os.RemoveAll(lib); err != nil {
+		t.Fatal(err)
+	}
+	if !ix.Refresh() {
+		t.Fatal("refresh missed the changes")
+	}
+	var names []string
+	for _, wt := range ix.Worktrees() {
+		names = append(names, wt.RepoName+"/"+wt.Worktree.Name+"@"+wt.Worktree.Branch)
+	}
+	if got := strings.Join(names, ","); got != "app/app@main,app/app-feat@feat" {
+		t.Fatalf("unexpected worktrees: %s", got)
+	}
+}
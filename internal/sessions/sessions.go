@@ -0,0 +1,133 @@
+// Package sessions persists the layout of worktree sessions so they can be
+// rebuilt after the tmux server is gone.
+package sessions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/config"
+)
+
+// Session is a saved worktree session, keyed by worktree path.
+type Session struct {
+	Name     string    `json:"name"`
+	RepoRoot string    `json:"repo_root"`
+	Path     string    `json:"path"`
+	SavedAt  time.Time `json:"saved_at"`
+	Windows  []Window  `json:"windows"`
+}
+
+type Window struct {
+	Name   string `json:"name"`
+	Layout string `json:"layout"`
+	Active bool   `json:"active"`
+	Panes  []Pane `json:"panes"`
+}
+
+// Pane records the pane's directory and the command line of its foreground
+// process, if any.
+type Pane struct {
+	Dir     string `json:"dir"`
+	Command string `json:"command,omitempty"`
+	Active  bool   `json:"active"`
+}
+
+type Store struct {
+	Sessions []Session `json:"sessions"`
+	path     string
+}
+
+// mu serializes Update calls within the process; the TUI saves from a
+// background command.
+var mu sync.Mutex
+
+func Path() string {
+	return filepath.Join(config.Dir(), "sessions.json")
+}
+
+func Load() (*Store, error) {
+	s := &Store{path: Path()}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return s, nil
+	}
+	return s, nil
+}
+
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// A unique temp file keeps concurrent saves, e.g. the TUI's periodic
+	// save and treemux restore, from writing into each other's file before
+	// the rename.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Update loads the store, applies fn and saves the result.
+func Update(fn func(*Store)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	fn(s)
+	return s.Save()
+}
+
+func (s *Store) Get(path string) (Session, bool) {
+	for _, sess := range s.Sessions {
+		if sess.Path == path {
+			return sess, true
+		}
+	}
+	return Session{}, false
+}
+
+// Put adds or replaces the session saved for sess.Path.
+func (s *Store) Put(sess Session) {
+	s.Remove(sess.Path)
+	s.Sessions = append(s.Sessions, sess)
+	sort.Slice(s.Sessions, func(i, j int) bool { return s.Sessions[i].Path < s.Sessions[j].Path })
+}
+
+func (s *Store) Remove(path string) {
+	filtered := s.Sessions[:0]
+	for _, sess := range s.Sessions {
+		if sess.Path != path {
+			filtered = append(filtered, sess)
+		}
+	}
+	s.Sessions = filtered
+}
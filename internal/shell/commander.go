@@ -1,12 +1,14 @@
 package shell
 
 import (
+	"os"
 	"os/exec"
 )
 
 type Commander interface {
 	Run(name string, args ...string) ([]byte, error)
 	RunDir(dir, name string, args ...string) ([]byte, error)
+	RunEnv(dir string, env []string, name string, args ...string) ([]byte, error)
 }
 
 type ExecCommander struct{}
@@ -21,3 +23,11 @@ func (e *ExecCommander) RunDir(dir, name string, args ...string) ([]byte, error)
 	cmd.Dir = dir
 	return cmd.CombinedOutput()
 }
+
+// RunEnv runs name in dir with env appended to the current environment.
+func (e *ExecCommander) RunEnv(dir string, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,60 @@
+package shell
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// CommandError is a command that exited with an error.
+type CommandError struct {
+	// Cmd names the command in messages, e.g. "git worktree add".
+	Cmd      string
+	Args     []string
+	ExitCode int
+	// Stderr is the command's output; git and tmux report errors there.
+	Stderr string
+	Err    error
+	// Cause is a sentinel error naming the reason, or nil.
+	Cause error
+}
+
+// NewCommandError wraps err from running cmd with args, which printed out.
+func NewCommandError(cmd string, args []string, out []byte, err error) *CommandError {
+	e := &CommandError{Cmd: cmd, Args: args, ExitCode: -1, Stderr: strings.TrimSpace(string(out)), Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	}
+	return e
+}
+
+// Error is the command's own message when it printed one, e.g. "git worktree
+// add: a branch named 'x' already exists".
+func (e *CommandError) Error() string {
+	if msg := e.Message(); msg != "" {
+		return e.Cmd + ": " + msg
+	}
+	return e.Cmd + ": " + e.Err.Error()
+}
+
+// Message is the fatal or error line of the output without its prefix, or
+// the last line when there is none.
+func (e *CommandError) Message() string {
+	lines := strings.Split(e.Stderr, "\n")
+	for _, line := range lines {
+		for _, prefix := range []string{"fatal: ", "error: "} {
+			if msg, ok := strings.CutPrefix(line, prefix); ok {
+				return strings.TrimSpace(msg)
+			}
+		}
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func (e *CommandError) Unwrap() []error {
+	if e.Cause != nil {
+		return []error{e.Err, e.Cause}
+	}
+	return []error{e.Err}
+}
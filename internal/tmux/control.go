@@ -0,0 +1,213 @@
+package tmux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Control-mode notifications (see CONTROL MODE in tmux(1)).
+const (
+	EventSessionsChanged      = "sessions-changed"
+	EventSessionChanged       = "session-changed"
+	EventSessionRenamed       = "session-renamed"
+	EventSessionWindowChanged = "session-window-changed"
+	EventWindowAdd            = "window-add"
+	EventWindowClose          = "window-close"
+	EventWindowRenamed        = "window-renamed"
+	EventUnlinkedWindowAdd    = "unlinked-window-add"
+	EventUnlinkedWindowClose  = "unlinked-window-close"
+	EventLayoutChange         = "layout-change"
+	EventPaneModeChanged      = "pane-mode-changed"
+	EventOutput               = "output"
+	EventExit                 = "exit"
+)
+
+// outputDebounce coalesces bursts of %output for a pane into one event.
+const outputDebounce = 100 * time.Millisecond
+
+// ControlEvent is a notification from a control-mode client. Args holds the
+// notification's arguments; the last one may contain spaces (e.g. a session
+// name). Output events carry the pane id in Args[0] and the unescaped bytes
+// in Data; ControlClient coalesces them and leaves Data empty.
+type ControlEvent struct {
+	Name string
+	Args []string
+	Data string
+}
+
+// ParseControlLine parses a single notification line. Lines that are not
+// notifications (command output, %begin/%end/%error guards) return false.
+func ParseControlLine(line string) (ControlEvent, bool) {
+	if !strings.HasPrefix(line, "%") {
+		return ControlEvent{}, false
+	}
+	name, rest, _ := strings.Cut(line[1:], " ")
+	switch name {
+	case "begin", "end", "error", "":
+		return ControlEvent{}, false
+	case EventOutput:
+		pane, data, _ := strings.Cut(rest, " ")
+		return ControlEvent{Name: name, Args: []string{pane}, Data: unescapeOutput(data)}, true
+	case EventSessionRenamed, EventSessionChanged, EventWindowRenamed, "unlinked-window-renamed", "client-session-changed":
+		// id followed by a name that may contain spaces
+		id, value, _ := strings.Cut(rest, " ")
+		return ControlEvent{Name: name, Args: []string{id, value}}, true
+	}
+	return ControlEvent{Name: name, Args: strings.Fields(rest)}, true
+}
+
+// unescapeOutput decodes the \ooo octal escapes tmux uses for %output.
+func unescapeOutput(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// ControlClient is a read-only tmux -C client. It follows one session at a
+// time; %output notifications are only sent for panes of that session.
+type ControlClient struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	events chan ControlEvent
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[string]bool
+}
+
+// StartControl attaches a control-mode client to session.
+func (t *Tmux) StartControl(session string) (*ControlClient, error) {
+	args := []string{"-C"}
+	// $TMUX is "socket,pid,session"; keep talking to the same server when
+	// running inside tmux, but do not trip the nested-session check.
+	if socket, _, ok := strings.Cut(os.Getenv("TMUX"), ","); ok && socket != "" {
+		args = append(args, "-S", socket)
+	}
+	args = append(args, "attach-session", "-r", "-f", "ignore-size", "-t", "="+session)
+	cmd := exec.Command("tmux", args...)
+	cmd.Env = withoutEnv(os.Environ(), "TMUX")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &ControlClient{cmd: cmd, stdin: stdin, events: make(chan ControlEvent, 64), pending: map[string]bool{}}
+	go c.read(stdout)
+	return c, nil
+}
+
+// Events is closed after the client exits.
+func (c *ControlClient) Events() <-chan ControlEvent {
+	return c.events
+}
+
+// Follow switches the client to session so its panes' output is reported.
+func (c *ControlClient) Follow(session string) error {
+	_, err := fmt.Fprintf(c.stdin, "switch-client -t '=%s'\n", strings.ReplaceAll(session, "'", `'\''`))
+	return err
+}
+
+// Close detaches the client and waits for it to exit. Pending events are
+// discarded.
+func (c *ControlClient) Close() error {
+	c.stdin.Close()
+	go func() {
+		for range c.events {
+		}
+	}()
+	return c.cmd.Wait()
+}
+
+func (c *ControlClient) read(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	inBlock := false
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "%begin "):
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "%end "), strings.HasPrefix(line, "%error "):
+			inBlock = false
+			continue
+		case inBlock:
+			continue
+		}
+		ev, ok := ParseControlLine(line)
+		if !ok {
+			continue
+		}
+		if ev.Name == EventOutput {
+			c.debounceOutput(ev.Args[0])
+			continue
+		}
+		c.events <- ev
+		if ev.Name == EventExit {
+			break
+		}
+	}
+	c.mu.Lock()
+	c.closed = true
+	close(c.events)
+	c.mu.Unlock()
+}
+
+// debounceOutput reports output for pane at most once per outputDebounce.
+// The data itself is dropped; listeners re-capture the pane instead.
+func (c *ControlClient) debounceOutput(pane string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[pane] {
+		return
+	}
+	c.pending[pane] = true
+	time.AfterFunc(outputDebounce, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.pending, pane)
+		if c.closed {
+			return
+		}
+		select {
+		case c.events <- ControlEvent{Name: EventOutput, Args: []string{pane}}:
+		default:
+		}
+	})
+}
+
+func withoutEnv(env []string, key string) []string {
+	out := env[:0:0]
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package tmux
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nicobailon/treemux/internal/shell"
+)
+
+// Causes of a failed tmux command, matched with errors.Is.
+var (
+	ErrNoServer        = errors.New("no tmux server running")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExists   = errors.New("session already exists")
+)
+
+// CommandError is a tmux command that exited with an error. Its Cause is one
+// of the Err* values above, or nil.
+type CommandError = shell.CommandError
+
+// run runs tmux with args. Failures are returned as a *CommandError.
+func (t *Tmux) run(args ...string) ([]byte, error) {
+	out, err := t.Cmd.Run("tmux", args...)
+	if err == nil {
+		return out, nil
+	}
+	cmd := "tmux"
+	if len(args) > 0 {
+		cmd += " " + args[0]
+	}
+	e := shell.NewCommandError(cmd, args, out, err)
+	switch {
+	case strings.HasPrefix(e.Stderr, "no server running"), strings.HasPrefix(e.Stderr, "error connecting to"):
+		e.Cause = ErrNoServer
+	case strings.HasPrefix(e.Stderr, "can't find session"), strings.HasPrefix(e.Stderr, "session not found"):
+		e.Cause = ErrSessionNotFound
+	case strings.HasPrefix(e.Stderr, "duplicate session"):
+		e.Cause = ErrSessionExists
+	}
+	return out, e
+}
@@ -0,0 +1,48 @@
+package tmux
+
+import "strings"
+
+// User options treemux sets on the sessions it creates, so a session can be
+// matched to its worktree after a rename and unrelated sessions are left
+// alone.
+const (
+	OptionRepo     = "@treemux_repo"
+	OptionWorktree = "@treemux_worktree"
+	OptionBranch   = "@treemux_branch"
+)
+
+// Owner is the worktree a session belongs to. The zero value means the
+// session is not managed by treemux.
+type Owner struct {
+	Repo     string
+	Worktree string
+	Branch   string
+}
+
+func (o Owner) Managed() bool {
+	return o.Worktree != ""
+}
+
+// SetOwner tags session with o using a single tmux call.
+func (t *Tmux) SetOwner(session string, o Owner) error {
+	_, err := t.run(
+		"set-option", "-q", "-t", session, OptionRepo, o.Repo, ";",
+		"set-option", "-q", "-t", session, OptionWorktree, o.Worktree, ";",
+		"set-option", "-q", "-t", session, OptionBranch, o.Branch)
+	return err
+}
+
+// parseOwners reads "name:branch:worktree" lines; git does not allow ':' in
+// branch names, so only the worktree path may contain one.
+func parseOwners(snap *Snapshot, out string) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if info, ok := snap.Sessions[fields[0]]; ok {
+			info.Owner.Branch = fields[1]
+			info.Owner.Worktree = fields[2]
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package tmux
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot is the state of every session on the server, read with one
+// list-sessions, list-panes -a and list-clients call each (and a second
+// list-sessions for the owners of managed sessions). Fields are separated by ':',
+// which tmux does not allow in session names (tabs are escaped in -F output).
+type Snapshot struct {
+	Names    []string
+	Sessions map[string]*SessionInfo
+	PanePIDs map[string][]int
+}
+
+func (s *Snapshot) Has(name string) bool {
+	_, ok := s.Sessions[name]
+	return ok
+}
+
+// Owned returns the session tagged with worktree, whatever its name.
+func (s *Snapshot) Owned(worktree string) (string, bool) {
+	if worktree == "" {
+		return "", false
+	}
+	for _, name := range s.Names {
+		if s.Sessions[name].Owner.Worktree == worktree {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Snapshot returns an empty snapshot when no server is running.
+func (t *Tmux) Snapshot() *Snapshot {
+	snap := &Snapshot{Sessions: map[string]*SessionInfo{}, PanePIDs: map[string][]int{}}
+	out, err := t.run("list-sessions", "-F", "#{session_name}:#{session_windows}:#{session_activity}:#{"+OptionRepo+"}")
+	if err != nil {
+		return snap
+	}
+	if parseSessions(snap, string(out)) {
+		// Paths may contain ':', so the worktree gets a call of its own,
+		// made only when treemux manages a session.
+		if out, err := t.run("list-sessions", "-F", "#{session_name}:#{"+OptionBranch+"}:#{"+OptionWorktree+"}"); err == nil {
+			parseOwners(snap, string(out))
+		}
+	}
+	if out, err := t.run("list-panes", "-a", "-F", "#{session_name}:#{pane_pid}"); err == nil {
+		parsePanes(snap, string(out))
+	}
+	if out, err := t.run("list-clients", "-F", "#{client_session}:#{client_control_mode}"); err == nil {
+		parseClients(snap, string(out))
+	}
+	return snap
+}
+
+// parseSessions reports whether any session is tagged with a repo.
+func parseSessions(snap *Snapshot, out string) bool {
+	managed := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, ":", 4)
+		if len(fields) < 3 || fields[0] == "" {
+			continue
+		}
+		info := &SessionInfo{}
+		info.Windows, _ = strconv.Atoi(fields[1])
+		if ts, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			info.LastActivity = time.Unix(ts, 0)
+		}
+		if len(fields) == 4 && fields[3] != "" {
+			info.Owner.Repo = fields[3]
+			managed = true
+		}
+		snap.Names = append(snap.Names, fields[0])
+		snap.Sessions[fields[0]] = info
+	}
+	sort.Strings(snap.Names)
+	return managed
+}
+
+// parseClients marks sessions with an attached client as active. Control-mode
+// clients (such as treemux's own) do not count.
+func parseClients(snap *Snapshot, out string) {
+	for _, line := range strings.Split(out, "\n") {
+		name, control, ok := strings.Cut(line, ":")
+		if !ok || control == "1" {
+			continue
+		}
+		if info, ok := snap.Sessions[name]; ok {
+			info.IsActive = true
+		}
+	}
+}
+
+func parsePanes(snap *Snapshot, out string) {
+	for _, line := range strings.Split(out, "\n") {
+		name, pidStr, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if info, ok := snap.Sessions[name]; ok {
+			info.Panes++
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(pidStr)); err == nil {
+			snap.PanePIDs[name] = append(snap.PanePIDs[name], pid)
+		}
+	}
+}
+
+// ProcessTree is the system process table, read with a single ps call.
+type ProcessTree struct {
+	names    map[int]string
+	children map[int][]int
+}
+
+func (t *Tmux) ProcessTree() (*ProcessTree, error) {
+	out, err := t.Cmd.Run("ps", "-A", "-o", "pid=,ppid=,comm=")
+	if err != nil {
+		return nil, err
+	}
+	return parseProcessTree(string(out)), nil
+}
+
+func parseProcessTree(out string) *ProcessTree {
+	tree := &ProcessTree{names: map[int]string{}, children: map[int][]int{}}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		tree.names[pid] = strings.Join(fields[2:], " ")
+		tree.children[ppid] = append(tree.children[ppid], pid)
+	}
+	return tree
+}
+
+// Name returns the command name of pid.
+func (p *ProcessTree) Name(pid int) string {
+	return p.names[pid]
+}
+
+func (p *ProcessTree) Children(pid int) []int {
+	return p.children[pid]
+}
+
+// Names returns the distinct command names of pids and all their
+// descendants, sorted.
+func (p *ProcessTree) Names(pids []int) []string {
+	seen := map[string]bool{}
+	visited := map[int]bool{}
+	var walk func(pid int)
+	walk = func(pid int) {
+		if visited[pid] {
+			return
+		}
+		visited[pid] = true
+		if name, ok := p.names[pid]; ok {
+			seen[name] = true
+		}
+		for _, child := range p.children[pid] {
+			walk(child)
+		}
+	}
+	for _, pid := range pids {
+		walk(pid)
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
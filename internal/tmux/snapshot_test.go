@@ -0,0 +1,168 @@
+package tmux
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSnapshot(t *testing.T) {
+	snap := &Snapshot{Sessions: map[string]*SessionInfo{}, PanePIDs: map[string][]int{}}
+	if !parseSessions(snap, "web:2:1700000000:/src/web\napi:1:1700000100:\n") {
+		t.Fatal("expected a managed session")
+	}
+	parsePanes(snap, "web:100\nweb:200\napi:300\nweb:400\n")
+	parseClients(snap, "web:0\napi:1\n")
+	parseOwners(snap, "web:feat/x:/src/web-feat:x\napi::\n")
+
+	if strings.Join(snap.Names, ",") != "api,web" {
+		t.Fatalf("unexpected names: %v", snap.Names)
+	}
+	web := snap.Sessions["web"]
+	if web.Windows != 2 || web.Panes != 3 || !web.IsActive || web.LastActivity.Unix() != 1700000000 {
+		t.Fatalf("unexpected web info: %+v", web)
+	}
+	if api := snap.Sessions["api"]; api.IsActive || api.Panes != 1 {
+		t.Fatalf("unexpected api info: %+v", api)
+	}
+	if len(snap.PanePIDs["web"]) != 3 || !snap.Has("api") || snap.Has("db") {
+		t.Fatalf("unexpected panes: %v", snap.PanePIDs)
+	}
+	if owner := web.Owner; owner.Repo != "/src/web" || owner.Branch != "feat/x" || owner.Worktree != "/src/web-feat:x" {
+		t.Fatalf("unexpected owner: %+v", owner)
+	}
+	if name, ok := snap.Owned("/src/web-feat:x"); !ok || name != "web" || snap.Sessions["api"].Owner.Managed() {
+		t.Fatalf("unexpected ownership: %s %v", name, ok)
+	}
+}
+
+func TestProcessTreeNames(t *testing.T) {
+	tree := parseProcessTree(`    1     0 init
+  100     1 zsh
+  101   100 node
+  102   101 esbuild
+  200     1 bash
+  201   200 vim
+  300     1 Google Chrome
+`)
+	if got := strings.Join(tree.Names([]int{100}), ","); got != "esbuild,node,zsh" {
+		t.Fatalf("unexpected descendants of 100: %s", got)
+	}
+	if got := strings.Join(tree.Names([]int{200, 300}), ","); got != "Google Chrome,bash,vim" {
+		t.Fatalf("unexpected names: %s", got)
+	}
+}
+
+func TestParseControlLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want ControlEvent
+		ok   bool
+	}{
+		{"%sessions-changed", ControlEvent{Name: EventSessionsChanged, Args: []string{}}, true},
+		{"%session-renamed $3 my session", ControlEvent{Name: EventSessionRenamed, Args: []string{"$3", "my session"}}, true},
+		{"%window-add @7", ControlEvent{Name: EventWindowAdd, Args: []string{"@7"}}, true},
+		{`%output %4 hi\015\012\134x`, ControlEvent{Name: EventOutput, Args: []string{"%4"}, Data: "hi\r\n\\x"}, true},
+		{"%begin 1792158315 468 0", ControlEvent{}, false},
+		{"plain command output", ControlEvent{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseControlLine(tt.line)
+		if ok != tt.ok || got.Name != tt.want.Name || got.Data != tt.want.Data || strings.Join(got.Args, "|") != strings.Join(tt.want.Args, "|") {
+			t.Errorf("ParseControlLine(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	windows := parseWindows("1:1:b25d,80x24,0,0{40x24,0,0,3,39x24,41,0,4}:dev: server\n0:0:a1b2,80x24,0,0,2:editor\n")
+	parseWindowPanes(windows, "1:1:1:300:node:/src/app:web\n0:0:1:200:nvim:/src/app\n1:0:0:100:zsh:/src/app\n")
+	if len(windows) != 2 || windows[0].Name != "editor" || windows[1].Name != "dev: server" || !windows[1].Active {
+		t.Fatalf("unexpected windows: %+v", windows)
+	}
+	dev := windows[1]
+	if dev.Layout != "b25d,80x24,0,0{40x24,0,0,3,39x24,41,0,4}" || len(dev.Panes) != 2 {
+		t.Fatalf("unexpected dev window: %+v", dev)
+	}
+	if p := dev.Panes[1]; p.Index != 1 || !p.Active || p.PID != 300 || p.Command != "node" || p.Dir != "/src/app:web" {
+		t.Fatalf("unexpected pane: %+v", p)
+	}
+}
+
+// failingCommander fails every command with out as its output.
+type failingCommander struct{ out string }
+
+func (f failingCommander) Run(name string, args ...string) ([]byte, error) {
+	return []byte(f.out), errors.New("exit status 1")
+}
+
+func (f failingCommander) RunDir(dir, name string, args ...string) ([]byte, error) {
+	return f.Run(name, args...)
+}
+
+func (f failingCommander) RunEnv(dir string, env []string, name string, args ...string) ([]byte, error) {
+	return f.Run(name, args...)
+}
+
+func TestCommandErrorCause(t *testing.T) {
+	tests := []struct {
+		out  string
+		want error
+	}{
+		{"can't find session: web\n", ErrSessionNotFound},
+		{"no server running on /tmp/tmux-1000/default\n", ErrNoServer},
+		{"error connecting to /tmp/tmux-1000/default (No such file or directory)\n", ErrNoServer},
+		{"duplicate session: web\n", ErrSessionExists},
+	}
+	for _, tt := range tests {
+		err := (&Tmux{Cmd: failingCommander{tt.out}}).KillSession("web")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.out, err, tt.want)
+		}
+	}
+	err := (&Tmux{Cmd: failingCommander{"can't find session: web\n"}}).KillSession("web")
+	if err.Error() != "tmux kill-session: can't find session: web" {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+}
+
+func TestDebounceOutput(t *testing.T) {
+	c := &ControlClient{events: make(chan ControlEvent, 8), pending: map[string]bool{}}
+	collect := func() map[string]int {
+		time.Sleep(3 * outputDebounce)
+		got := map[string]int{}
+		for {
+			select {
+			case ev := <-c.events:
+				if ev.Name != EventOutput || len(ev.Args) != 1 {
+					t.Fatalf("unexpected event %+v", ev)
+				}
+				got[ev.Args[0]]++
+			default:
+				return got
+			}
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		c.debounceOutput("%1")
+	}
+	c.debounceOutput("%2")
+	if got := collect(); got["%1"] != 1 || got["%2"] != 1 || len(got) != 2 {
+		t.Fatalf("burst produced %v, want one event per pane", got)
+	}
+
+	c.debounceOutput("%1")
+	if got := collect(); got["%1"] != 1 || len(got) != 1 {
+		t.Fatalf("output after the window produced %v, want a new event", got)
+	}
+
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.debounceOutput("%1")
+	if got := collect(); len(got) != 0 {
+		t.Fatalf("closed client produced %v", got)
+	}
+}
@@ -15,22 +15,88 @@ type Tmux struct {
 }
 
 func (t *Tmux) HasSession(name string) bool {
-	_, err := t.Cmd.Run("tmux", "has-session", "-t", name)
+	_, err := t.run("has-session", "-t", name)
 	return err == nil
 }
 
 func (t *Tmux) NewSession(name, path string) error {
-	_, err := t.Cmd.Run("tmux", "new-session", "-d", "-s", name, "-c", path)
+	_, err := t.run("new-session", "-d", "-s", name, "-c", path)
+	return err
+}
+
+// NewSessionWindow creates a detached session whose first window is named
+// window and returns that window's id.
+func (t *Tmux) NewSessionWindow(name, path, window string) (string, error) {
+	args := []string{"new-session", "-d", "-s", name, "-c", path, "-P", "-F", "#{window_id}"}
+	if window != "" {
+		args = append(args, "-n", window)
+	}
+	out, err := t.run(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// NewWindow appends a window to session and returns its id.
+func (t *Tmux) NewWindow(session, name, path string) (string, error) {
+	args := []string{"new-window", "-d", "-t", session + ":", "-c", path, "-P", "-F", "#{window_id}"}
+	if name != "" {
+		args = append(args, "-n", name)
+	}
+	out, err := t.run(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SplitWindow splits target and returns the new pane's id. horizontal places
+// the panes side by side.
+func (t *Tmux) SplitWindow(target, path string, horizontal bool, size string) (string, error) {
+	args := []string{"split-window", "-d", "-t", target, "-c", path, "-P", "-F", "#{pane_id}"}
+	if horizontal {
+		args = append(args, "-h")
+	} else {
+		args = append(args, "-v")
+	}
+	if size != "" {
+		args = append(args, "-l", size)
+	}
+	out, err := t.run(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (t *Tmux) SendKeys(target, keys string) error {
+	_, err := t.run("send-keys", "-t", target, keys, "Enter")
+	return err
+}
+
+func (t *Tmux) SelectLayout(target, layout string) error {
+	_, err := t.run("select-layout", "-t", target, layout)
+	return err
+}
+
+func (t *Tmux) SelectWindow(target string) error {
+	_, err := t.run("select-window", "-t", target)
 	return err
 }
 
 func (t *Tmux) KillSession(name string) error {
-	_, err := t.Cmd.Run("tmux", "kill-session", "-t", name)
+	_, err := t.run("kill-session", "-t", name)
+	return err
+}
+
+func (t *Tmux) RenameSession(from, to string) error {
+	_, err := t.run("rename-session", "-t", from, to)
 	return err
 }
 
 func (t *Tmux) SwitchClient(name string) error {
-	_, err := t.Cmd.Run("tmux", "switch-client", "-t", name)
+	_, err := t.run("switch-client", "-t", name)
 	return err
 }
 
@@ -40,22 +106,17 @@ func (t *Tmux) AttachOrCreate(name, path string) error {
 			return err
 		}
 	}
-	_, err := t.Cmd.Run("tmux", "attach", "-t", name)
+	_, err := t.run("attach", "-t", name)
 	return err
 }
 
+// ListSessions returns every session with its info, using two batched tmux
+// calls (see Snapshot).
 func (t *Tmux) ListSessions() ([]Session, error) {
-	out, err := t.Cmd.Run("tmux", "list-sessions", "-F", "#{session_name}")
-	if err != nil {
-		return []Session{}, nil
-	}
-	var sessions []Session
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		if line == "" {
-			continue
-		}
-		info, _ := t.SessionInfo(line)
-		sessions = append(sessions, Session{Name: line, Info: info})
+	snap := t.Snapshot()
+	sessions := []Session{}
+	for _, name := range snap.Names {
+		sessions = append(sessions, Session{Name: name, Info: snap.Sessions[name]})
 	}
 	return sessions, nil
 }
@@ -70,14 +131,16 @@ type SessionInfo struct {
 	Panes        int
 	LastActivity time.Time
 	IsActive     bool
+	// Owner is only filled in by Snapshot.
+	Owner Owner
 }
 
 func (t *Tmux) SessionInfo(name string) (*SessionInfo, error) {
-	winOut, err := t.Cmd.Run("tmux", "list-windows", "-t", name)
+	winOut, err := t.run("list-windows", "-t", name)
 	if err != nil {
 		return nil, err
 	}
-	paneOut, err := t.Cmd.Run("tmux", "list-panes", "-t", name)
+	paneOut, err := t.run("list-panes", "-t", name)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +150,7 @@ func (t *Tmux) SessionInfo(name string) (*SessionInfo, error) {
 		Panes:   countNonEmptyLines(string(paneOut)),
 	}
 
-	activityOut, err := t.Cmd.Run("tmux", "display-message", "-t", name, "-p", "#{session_activity}:#{session_attached}")
+	activityOut, err := t.run("display-message", "-t", name, "-p", "#{session_activity}:#{session_attached}")
 	if err == nil {
 		parts := strings.Split(strings.TrimSpace(string(activityOut)), ":")
 		if len(parts) >= 2 {
@@ -102,50 +165,21 @@ func (t *Tmux) SessionInfo(name string) (*SessionInfo, error) {
 }
 
 func (t *Tmux) RunningProcesses(name string) ([]string, error) {
-	out, err := t.Cmd.Run("tmux", "list-panes", "-t", name, "-F", "#{pane_pid}")
+	out, err := t.run("list-panes", "-s", "-t", name, "-F", "#{pane_pid}")
 	if err != nil {
 		return nil, err
 	}
-	pids := []string{}
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			pids = append(pids, line)
+	var pids []int
+	for _, line := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(line); err == nil {
+			pids = append(pids, pid)
 		}
 	}
-
-	procs := map[string]struct{}{}
-	for _, pid := range pids {
-		t.collectProcessNames(pid, procs)
-	}
-
-	var list []string
-	for name := range procs {
-		list = append(list, name)
-	}
-	return list, nil
-}
-
-func (t *Tmux) collectProcessNames(pid string, out map[string]struct{}) {
-	if pid == "" {
-		return
-	}
-	if b, err := t.Cmd.Run("ps", "-o", "comm=", "-p", pid); err == nil {
-		name := strings.TrimSpace(string(b))
-		if name != "" {
-			out[name] = struct{}{}
-		}
-	}
-	childOut, err := t.Cmd.Run("pgrep", "-P", pid)
+	tree, err := t.ProcessTree()
 	if err != nil {
-		return
-	}
-	for _, line := range strings.Split(strings.TrimSpace(string(childOut)), "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			t.collectProcessNames(line, out)
-		}
+		return nil, err
 	}
+	return tree.Names(pids), nil
 }
 
 func (t *Tmux) IsInsideTmux() bool {
@@ -153,7 +187,7 @@ func (t *Tmux) IsInsideTmux() bool {
 }
 
 func (t *Tmux) CapturePane(sessionName string, lines int) (string, error) {
-	out, err := t.Cmd.Run("tmux", "capture-pane", "-t", sessionName, "-p",
+	out, err := t.run("capture-pane", "-t", sessionName, "-p",
 		"-S", fmt.Sprintf("-%d", lines), "-E", "-1")
 	if err != nil {
 		return "", err
@@ -0,0 +1,113 @@
+package tmux
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Window is one window of a session as reported by list-windows. Layout is
+// tmux's layout string, which select-layout accepts as-is.
+type Window struct {
+	Index  int
+	Name   string
+	Layout string
+	Active bool
+	Panes  []Pane
+}
+
+// Pane is one pane of a window. Command is the name of its foreground
+// process and PID that of the process tmux started in it (usually a shell).
+type Pane struct {
+	Index   int
+	PID     int
+	Active  bool
+	Command string
+	Dir     string
+}
+
+// Windows returns the windows of session with their panes, in index order.
+func (t *Tmux) Windows(session string) ([]Window, error) {
+	out, err := t.run("list-windows", "-t", session, "-F", "#{window_index}:#{window_active}:#{window_layout}:#{window_name}")
+	if err != nil {
+		return nil, err
+	}
+	windows := parseWindows(string(out))
+	out, err = t.run("list-panes", "-s", "-t", session, "-F", "#{window_index}:#{pane_index}:#{pane_active}:#{pane_pid}:#{pane_current_command}:#{pane_current_path}")
+	if err != nil {
+		return nil, err
+	}
+	parseWindowPanes(windows, string(out))
+	return windows, nil
+}
+
+// parseWindows reads list-windows output. The layout string contains no ':'
+// and the name comes last, so names may.
+func parseWindows(out string) []Window {
+	var windows []Window
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, ":", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		idx, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		windows = append(windows, Window{Index: idx, Active: fields[1] == "1", Layout: fields[2], Name: fields[3]})
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Index < windows[j].Index })
+	return windows
+}
+
+func parseWindowPanes(windows []Window, out string) {
+	byIndex := map[int]*Window{}
+	for i := range windows {
+		byIndex[windows[i].Index] = &windows[i]
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, ":", 6)
+		if len(fields) != 6 {
+			continue
+		}
+		wi, err1 := strconv.Atoi(fields[0])
+		pi, err2 := strconv.Atoi(fields[1])
+		w, ok := byIndex[wi]
+		if err1 != nil || err2 != nil || !ok {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[3])
+		w.Panes = append(w.Panes, Pane{Index: pi, Active: fields[2] == "1", PID: pid, Command: fields[4], Dir: fields[5]})
+	}
+	for _, w := range byIndex {
+		sort.Slice(w.Panes, func(i, j int) bool { return w.Panes[i].Index < w.Panes[j].Index })
+	}
+}
+
+func (t *Tmux) SelectPane(target string) error {
+	_, err := t.run("select-pane", "-t", target)
+	return err
+}
+
+// CommandLines returns the full command line of each pid that still exists.
+func (t *Tmux) CommandLines(pids []int) map[int]string {
+	lines := map[int]string{}
+	if len(pids) == 0 {
+		return lines
+	}
+	list := make([]string, len(pids))
+	for i, pid := range pids {
+		list[i] = strconv.Itoa(pid)
+	}
+	out, _ := t.Cmd.Run("ps", "-o", "pid=,args=", "-p", strings.Join(list, ","))
+	for _, line := range strings.Split(string(out), "\n") {
+		pidStr, args, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if pid, err := strconv.Atoi(pidStr); err == nil {
+			lines[pid] = strings.TrimSpace(args)
+		}
+	}
+	return lines
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -14,8 +15,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/nicobailon/treemux/internal/config"
+	"github.com/nicobailon/treemux/internal/git"
 	"github.com/nicobailon/treemux/internal/recent"
 	"github.com/nicobailon/treemux/internal/scanner"
+	"github.com/nicobailon/treemux/internal/shell"
 	"github.com/nicobailon/treemux/internal/tmux"
 	"github.com/nicobailon/treemux/internal/tui/builders"
 	"github.com/nicobailon/treemux/internal/tui/components"
@@ -38,6 +41,12 @@ const (
 	stateCommandPalette
 	stateGridView
 	stateGridDetail
+	stateConfirmDelete
+	statePruneSelect
+	statePruneConfirm
+	stateRenameName
+	stateRenameBranch
+	stateLockReason
 )
 
 const defaultRefreshInterval = 3 * time.Second
@@ -63,6 +72,32 @@ type PendingAction struct {
 	Global      *scanner.RepoWorktree
 	CreateSvc   *workspace.Service
 	SelectAfter string
+	Branch      *workspace.BranchInfo
+	Prune       []pruneEntry
+	Rename      *renameTarget
+	Lock        *lockTarget
+}
+
+// renameTarget is the worktree being renamed and the service of its repo.
+type renameTarget struct {
+	svc     *workspace.Service
+	path    string
+	name    string
+	branch  string
+	global  bool
+	newName string
+}
+
+// lockTarget is the worktree being locked and the service of its repo.
+type lockTarget struct {
+	svc  *workspace.Service
+	path string
+	name string
+}
+
+type pruneEntry struct {
+	candidate workspace.PruneCandidate
+	selected  bool
 }
 
 type Navigation struct {
@@ -101,24 +136,56 @@ func (c CommandItem) FilterValue() string { return c.label + " " + c.desc }
 type dataLoadedMsg struct {
 	states  []workspace.WorktreeState
 	orphans []string
+	recents []recent.Entry
 }
 
 type globalDataLoadedMsg struct {
-	worktrees []scanner.RepoWorktree
-	orphans   []string
+	worktrees  []scanner.RepoWorktree
+	orphans    []string
+	collisions []string
+	// rescan asks for a background rescan of the search paths; rescanned
+	// marks the result of one.
+	rescan    bool
+	rescanned bool
+}
+
+type pruneCandidatesMsg struct {
+	candidates []workspace.PruneCandidate
 }
 
 type branchesMsg struct {
 	branches []string
+	remotes  []string
 }
 
 type resultMsg struct {
-	action string
-	err    error
+	action  string
+	err     error
+	warning string
+	// renamed is set by a rename, whose recent entries are moved on disk
+	// and still need moving in the store held by the model.
+	renamed *workspace.RenameResult
 }
 
 type refreshTickMsg struct{}
 type previewTickMsg struct{}
+type controlReloadMsg struct{}
+type worktreeChangedMsg struct {
+	path string
+}
+type worktreeStateMsg struct {
+	state workspace.WorktreeState
+	err   error
+}
+type controlStartedMsg struct {
+	client  *tmux.ControlClient
+	session string
+	err     error
+}
+type controlEventMsg struct {
+	event tmux.ControlEvent
+	ok    bool
+}
 type paneContentMsg struct {
 	sessionName string
 	content     string
@@ -127,6 +194,17 @@ type paneContentMsg struct {
 
 const previewRefreshInterval = 500 * time.Millisecond
 
+// While a control-mode client is connected, tmux changes arrive as events and
+// polling only picks up git changes.
+const (
+	controlRefreshInterval = 15 * time.Second
+	controlReloadDelay     = 150 * time.Millisecond
+)
+
+// sessionSaveInterval bounds how often session layouts are saved for
+// treemux restore.
+const sessionSaveInterval = time.Minute
+
 type model struct {
 	deps            Deps
 	data            WorkspaceData
@@ -146,12 +224,34 @@ type model struct {
 	refreshInFlight  int
 	paneContent string
 	paneSession string
+	// previewTicking is set while a preview tick is scheduled.
+	previewTicking bool
 	grid        views.GridState
+
+	watcher         *workspace.Watcher
+	sessionsSavedAt time.Time
+	control         *tmux.ControlClient
+	controlSession  string
+	controlStarting bool
+	reloadScheduled bool
+	// collisionWarning is the last session name collision reported, so a
+	// refresh does not repeat it.
+	collisionWarning string
+	// indexRescanning is set while the repo index is rebuilt in the
+	// background.
+	indexRescanning bool
+	// gridStatus is the git status of the grid's worktrees in global mode,
+	// by path.
+	gridStatus map[string]*git.StatusSummary
+	// services holds the workspace services of other repositories by root,
+	// so their git state cache survives between refreshes.
+	services map[string]*workspace.Service
 }
 
 type JumpTarget struct {
 	SessionName string
 	Path        string
+	RepoRoot    string
 	Create      bool
 }
 
@@ -170,10 +270,17 @@ func (a *App) Run() (*JumpTarget, error) {
 	m := initialModel(a.svc, a.cfg, a.tmux, a.inGitRepo)
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	finalModel, err := p.Run()
+	fm, ok := finalModel.(model)
+	if ok && fm.control != nil {
+		fm.control.Close()
+	}
+	if ok && fm.watcher != nil {
+		fm.watcher.Close()
+	}
 	if err != nil {
 		return nil, err
 	}
-	if fm, ok := finalModel.(model); ok && fm.jumpTarget != nil {
+	if ok && fm.jumpTarget != nil {
 		return fm.jumpTarget, nil
 	}
 	return nil, nil
@@ -238,6 +345,11 @@ func initialModel(svc *workspace.Service, cfg *config.Config, t *tmux.Tmux, inGi
 	cmdPalette.FilterInput.PromptStyle = theme.KeyStyle
 	cmdPalette.FilterInput.TextStyle = theme.TextStyle
 
+	var startToast *toast
+	if svc != nil && svc.ConfigWarning != nil {
+		startToast = &toast{message: svc.ConfigWarning.Error(), kind: toastWarning, expiresAt: time.Now().Add(toastDuration)}
+	}
+
 	return model{
 		deps: Deps{
 			Svc:         svc,
@@ -259,6 +371,10 @@ func initialModel(svc *workspace.Service, cfg *config.Config, t *tmux.Tmux, inGi
 		commandPalette:  cmdPalette,
 		spinner:         sp,
 		refreshInterval: defaultRefreshInterval,
+		controlStarting: true,
+		previewTicking:  true,
+		toast:           startToast,
+		services:        map[string]*workspace.Service{},
 	}
 }
 
@@ -266,13 +382,17 @@ func initialModel(svc *workspace.Service, cfg *config.Config, t *tmux.Tmux, inGi
 
 func (m model) Init() tea.Cmd {
 	if m.nav.GlobalMode {
-		return tea.Batch(m.spinner.Tick, loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux), m.tickCmd(), m.previewTickCmd())
+		return tea.Batch(m.spinner.Tick, loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux), m.tickCmd(), m.previewTickCmd(), startControlCmd(m.deps.Tmux))
 	}
-	return tea.Batch(m.spinner.Tick, loadDataCmd(m.deps.Svc), m.tickCmd(), m.previewTickCmd())
+	return tea.Batch(m.spinner.Tick, m.loadDataCmd(), m.tickCmd(), m.previewTickCmd(), startControlCmd(m.deps.Tmux))
 }
 
 func (m model) tickCmd() tea.Cmd {
-	return tea.Tick(m.refreshInterval, func(time.Time) tea.Msg {
+	interval := m.refreshInterval
+	if m.control != nil {
+		interval = max(interval, controlRefreshInterval)
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg {
 		return refreshTickMsg{}
 	})
 }
@@ -283,6 +403,56 @@ func (m model) previewTickCmd() tea.Cmd {
 	})
 }
 
+// schedulePreviewTick polls the selected session's pane while there is no
+// control client; with one, its output events drive the capture instead.
+func (m *model) schedulePreviewTick() tea.Cmd {
+	if m.previewTicking || m.control != nil {
+		return nil
+	}
+	m.previewTicking = true
+	return m.previewTickCmd()
+}
+
+// previewSelectedCmd captures the pane of the selected item's session and
+// points the control client at it.
+func (m *model) previewSelectedCmd() tea.Cmd {
+	sel, ok := m.list.SelectedItem().(listItem)
+	if !ok {
+		return nil
+	}
+	var sessionName string
+	switch sel.Kind {
+	case kindWorktree:
+		wt := sel.Data.(workspace.WorktreeState)
+		if wt.HasSession {
+			sessionName = wt.SessionName
+		}
+	case kindGlobal:
+		wt := sel.Data.(scanner.RepoWorktree)
+		if m.deps.Tmux.HasSession(wt.SessionName) {
+			sessionName = wt.SessionName
+		}
+	case kindOrphan:
+		sessionName = sel.ItemTitle
+	}
+	if sessionName == "" {
+		m.paneContent = ""
+		m.paneSession = ""
+		return nil
+	}
+	if m.control != nil {
+		// Output events drive the capture once the client follows the
+		// selected session.
+		if sessionName == m.controlSession && m.paneSession == sessionName {
+			return nil
+		}
+		if m.control.Follow(sessionName) == nil {
+			m.controlSession = sessionName
+		}
+	}
+	return loadPaneContentCmd(m.deps.Tmux, sessionName, 50)
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case spinner.TickMsg:
@@ -317,11 +487,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.data.States = builders.ReorderCurrentFirst(msg.states, repoRoot)
 		m.data.Orphans = msg.orphans
-		if m.deps.RecentStore != nil && repoRoot != "" {
-			m.data.RecentEntries = m.deps.RecentStore.GetOtherProjects(repoRoot, 5)
+		if repoRoot != "" {
+			m.data.RecentEntries = msg.recents
 		}
 		items := builders.BuildItems(m.data.States, m.data.Orphans, m.data.RecentEntries, repoRoot)
 		m.list.SetItems(items)
+		background := m.watchWorktreesCmd()
+		if m.deps.Svc != nil && time.Since(m.sessionsSavedAt) > sessionSaveInterval {
+			m.sessionsSavedAt = time.Now()
+			background = tea.Batch(background, saveSessionsCmd(m.deps.Svc, m.data.States))
+		}
 		if m.pending.SelectAfter != "" {
 			for i, item := range items {
 				if li, ok := item.(listItem); ok && li.Kind == kindWorktree && li.ItemTitle == m.pending.SelectAfter {
@@ -375,17 +550,43 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.grid.AvailIdx = 0
 				}
 			}
-			return m, m.loadGridContentCmd()
+			return m, tea.Batch(background, m.loadGridContentCmd())
 		}
-		return m, nil
+		if m.control != nil {
+			// The selected worktree may have gained or lost its session.
+			background = tea.Batch(background, m.previewSelectedCmd())
+		}
+		return m, background
 
 	case globalDataLoadedMsg:
-		m.nav.Loading = false
-		if m.refreshInFlight > 0 {
-			m.refreshInFlight--
+		if msg.rescanned {
+			m.indexRescanning = false
+			// Local mode switched in while rescanning and shares Orphans;
+			// the saved index is picked up on the next global load.
+			if !m.nav.GlobalMode {
+				return m, nil
+			}
+		} else {
+			m.nav.Loading = false
+			if m.refreshInFlight > 0 {
+				m.refreshInFlight--
+			}
+		}
+		var rescan tea.Cmd
+		if msg.rescan && !m.indexRescanning {
+			m.indexRescanning = true
+			rescan = rescanIndexCmd(m.deps.Cfg, m.deps.Tmux)
 		}
 		m.data.GlobalWorktrees = msg.worktrees
 		m.data.Orphans = msg.orphans
+		var warn tea.Cmd
+		if warning := strings.Join(msg.collisions, "; "); warning != m.collisionWarning {
+			m.collisionWarning = warning
+			if warning != "" {
+				m.toast = &toast{message: "Shared session names: " + warning + ". Use a session_name such as {{.RepoName}}/{{.Branch}}", kind: toastWarning, expiresAt: time.Now().Add(toastDuration)}
+				warn = toastExpireCmd()
+			}
+		}
 		items := builders.BuildGlobalItems(m.data.GlobalWorktrees, m.data.Orphans, m.deps.Tmux)
 		m.list.SetItems(items)
 		if m.pending.SelectAfter != "" {
@@ -451,15 +652,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.grid.AvailIdx = 0
 				}
 			}
-			return m, m.loadGridContentCmd()
+			return m, tea.Batch(warn, rescan, m.loadGridContentCmd(), m.loadGridStatusCmd())
 		}
-		return m, nil
+		var preview tea.Cmd
+		if m.control != nil {
+			preview = m.previewSelectedCmd()
+		}
+		return m, tea.Batch(warn, rescan, preview)
 
 	case branchesMsg:
 		items := []list.Item{}
 		for _, b := range msg.branches {
 			items = append(items, listItem{ItemTitle: b, ItemDesc: "base branch", Kind: kindWorktree})
 		}
+		if m.nav.NextBranchState == stateCreateBranch {
+			for _, b := range msg.remotes {
+				items = append(items, listItem{ItemTitle: b, ItemDesc: "remote branch (creates a tracking branch)", Kind: kindWorktree, Data: workspace.CreateOptions{RemoteBranch: b}})
+			}
+		}
 		m.menu.SetItems(items)
 		selectedIdx := 0
 		if m.deps.Svc != nil && m.deps.Svc.Git != nil {
@@ -483,7 +693,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.nav.State = m.nav.NextBranchState
 		return m, nil
 
+	case pruneCandidatesMsg:
+		m.pending.Prune = nil
+		for _, c := range msg.candidates {
+			if c.Reason != workspace.PruneActive {
+				m.pending.Prune = append(m.pending.Prune, pruneEntry{candidate: c, selected: c.Reason != workspace.PruneStale})
+			}
+		}
+		if len(m.pending.Prune) == 0 {
+			m.toast = &toast{message: "Nothing to prune", kind: toastInfo, expiresAt: time.Now().Add(toastDuration)}
+			return m, toastExpireCmd()
+		}
+		m.menu.SetItems(pruneMenuItems(m.pending.Prune))
+		m.menu.Select(0)
+		m.nav.State = statePruneSelect
+		return m, nil
+
 	case jumpMsg:
+		if m.deps.RecentStore != nil && msg.repoRoot != "" {
+			m.deps.RecentStore.Add(msg.repoRoot, msg.worktree, msg.sessionName, msg.path)
+			_ = m.deps.RecentStore.Save()
+		}
 		m.jumpTarget = &JumpTarget{SessionName: msg.sessionName, Path: msg.path}
 		return m, tea.Quit
 
@@ -494,9 +724,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.refreshInFlight--
 			}
 		}
-		if msg.err != nil {
+		var hookErr *workspace.HookError
+		if msg.err != nil && !(errors.As(msg.err, &hookErr) && !hookErr.Aborts()) {
 			m.toast = &toast{
-				message:   msg.err.Error(),
+				message:   errorMessage(msg.err),
 				kind:      toastError,
 				expiresAt: time.Now().Add(toastDuration),
 			}
@@ -511,22 +742,49 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.toast = &toast{message: "Session killed", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
 		case "adopt":
 			m.toast = &toast{message: "Session adopted", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+		case "rename":
+			m.toast = &toast{message: "Worktree renamed", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+			if r := msg.renamed; r != nil && m.deps.RecentStore != nil {
+				m.deps.RecentStore.Move(r.OldPath, r.Path, filepath.Base(r.Path), r.Session)
+			}
+		case "prune":
+			m.toast = &toast{message: "Worktrees pruned", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+		case "lock":
+			m.toast = &toast{message: "Worktree locked", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+		case "unlock":
+			m.toast = &toast{message: "Worktree unlocked", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+		case "repair":
+			m.toast = &toast{message: "Worktree links repaired", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+		case "prune-stale":
+			m.toast = &toast{message: "Stale worktree entries pruned", kind: toastSuccess, expiresAt: time.Now().Add(toastDuration)}
+		}
+		if hookErr != nil {
+			// The operation itself succeeded; only a follow-up hook failed.
+			m.toast = &toast{message: hookErr.Error(), kind: toastWarning, expiresAt: time.Now().Add(toastDuration)}
+		} else if msg.warning != "" {
+			m.toast = &toast{message: msg.warning, kind: toastWarning, expiresAt: time.Now().Add(toastDuration)}
 		}
 		switch msg.action {
-		case "create", "delete", "kill-session", "adopt":
+		case "create", "delete", "kill-session", "adopt", "prune", "rename", "lock", "unlock", "repair", "prune-stale":
 			m.nav.State = stateMain
 			m.pending.CreateSvc = nil
+			m.pending.Rename = nil
+			m.pending.Lock = nil
 			if m.nav.GlobalMode {
 				return m, tea.Batch(loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux), toastExpireCmd())
 			}
 			if m.deps.Svc == nil {
 				return m, toastExpireCmd()
 			}
-			return m, tea.Batch(loadDataCmd(m.deps.Svc), toastExpireCmd())
+			return m, tea.Batch(m.loadDataCmd(), toastExpireCmd())
 		}
 		return m, nil
 
 	case refreshTickMsg:
+		if m.control == nil && !m.controlStarting {
+			m.controlStarting = true
+			return m, tea.Batch(startControlCmd(m.deps.Tmux), m.tickCmd())
+		}
 		if m.refreshInFlight > 0 || m.nav.Loading {
 			return m, m.tickCmd()
 		}
@@ -538,34 +796,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.refreshInFlight--
 			return m, m.tickCmd()
 		}
-		return m, tea.Batch(loadDataCmd(m.deps.Svc), m.tickCmd())
+		return m, tea.Batch(m.loadDataCmd(), m.tickCmd())
 
 	case previewTickMsg:
-		sel, ok := m.list.SelectedItem().(listItem)
-		if !ok {
-			return m, m.previewTickCmd()
-		}
-		var sessionName string
-		switch sel.Kind {
-		case kindWorktree:
-			wt := sel.Data.(workspace.WorktreeState)
-			if wt.HasSession {
-				sessionName = wt.SessionName
-			}
-		case kindGlobal:
-			wt := sel.Data.(scanner.RepoWorktree)
-			if m.deps.Tmux.HasSession(wt.Worktree.Name) {
-				sessionName = wt.Worktree.Name
-			}
-		case kindOrphan:
-			sessionName = sel.ItemTitle
+		m.previewTicking = false
+		return m, tea.Batch(m.previewSelectedCmd(), m.schedulePreviewTick())
+
+	case worktreeChangedMsg:
+		cmds := []tea.Cmd{waitForWorktreeChange(m.watcher)}
+		if m.deps.Svc != nil {
+			cmds = append(cmds, refreshWorktreeCmd(m.deps.Svc, msg.path))
 		}
-		if sessionName != "" {
-			return m, tea.Batch(loadPaneContentCmd(m.deps.Tmux, sessionName, 50), m.previewTickCmd())
+		return m, tea.Batch(cmds...)
+
+	case worktreeStateMsg:
+		return m.handleWorktreeState(msg)
+
+	case controlStartedMsg:
+		return m.handleControlStarted(msg)
+
+	case controlEventMsg:
+		return m.handleControlEvent(msg)
+
+	case controlReloadMsg:
+		m.reloadScheduled = false
+		if m.refreshInFlight > 0 || m.nav.Loading {
+			return m, m.scheduleReload()
 		}
-		m.paneContent = ""
-		m.paneSession = ""
-		return m, m.previewTickCmd()
+		return m, m.reloadCmd()
 
 	case paneContentMsg:
 		if msg.err == nil && msg.content != "" {
@@ -580,7 +838,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				case kindGlobal:
 					wt := sel.Data.(scanner.RepoWorktree)
-					expectedSession = wt.Worktree.Name
+					expectedSession = wt.SessionName
 				case kindOrphan:
 					expectedSession = sel.ItemTitle
 				}
@@ -592,6 +850,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case gridStatusMsg:
+		if m.gridStatus == nil {
+			m.gridStatus = map[string]*git.StatusSummary{}
+		}
+		for path, status := range msg.statuses {
+			m.gridStatus[path] = status
+		}
+		if m.nav.GlobalMode {
+			m.applyGridStatus()
+		}
+		return m, nil
+
 	case views.GridContentMsg:
 		for i := range m.grid.Panels {
 			if content, ok := msg.Contents[m.grid.Panels[i].SessionName]; ok {
@@ -634,8 +904,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return handleOrphanBranch(&m, msg)
 	case stateActionMenu, stateOrphanMenu:
 		return handleActionMenu(&m, msg)
+	case stateConfirmDelete:
+		return handleConfirmDelete(&m, msg)
+	case statePruneSelect:
+		return handlePruneSelect(&m, msg)
+	case statePruneConfirm:
+		return handlePruneConfirm(&m, msg)
 	case stateCommandPalette:
 		return handleCommandPalette(&m, msg)
+	case stateRenameName:
+		return handleRenameName(&m, msg)
+	case stateLockReason:
+		return handleLockReason(&m, msg)
+	case stateRenameBranch:
+		return handleRenameBranch(&m, msg)
 	}
 
 	// main view handling
@@ -651,7 +933,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.skipNonSelectable(1)
 			m.paneContent = ""
 			m.paneSession = ""
-			
+			cmds = append(cmds, m.previewSelectedCmd())
 			return m, tea.Batch(cmds...)
 		case "k", "up":
 			m.list, cmd = m.list.Update(msg)
@@ -659,7 +941,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.skipNonSelectable(-1)
 			m.paneContent = ""
 			m.paneSession = ""
-			
+			cmds = append(cmds, m.previewSelectedCmd())
 			return m, tea.Batch(cmds...)
 		case "pgdown", "ctrl+f":
 			for i := 0; i < 10; i++ {
@@ -668,7 +950,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.skipNonSelectable(1)
 			m.paneContent = ""
 			m.paneSession = ""
-			return m, nil
+			return m, m.previewSelectedCmd()
 		case "pgup", "ctrl+b":
 			for i := 0; i < 10; i++ {
 				m.list, _ = m.list.Update(tea.KeyMsg{Type: tea.KeyUp})
@@ -676,13 +958,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.skipNonSelectable(-1)
 			m.paneContent = ""
 			m.paneSession = ""
-			return m, nil
+			return m, m.previewSelectedCmd()
 		}
 	}
 
 	if m.nav.State != stateGridView && m.nav.State != stateGridDetail {
 		m.list, cmd = m.list.Update(msg)
 		cmds = append(cmds, cmd)
+		if _, ok := msg.(tea.KeyMsg); ok && m.control != nil {
+			// Typing a filter can change the selection too.
+			cmds = append(cmds, m.previewSelectedCmd())
+		}
 	}
 
 	switch msg := msg.(type) {
@@ -719,7 +1005,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, tea.Batch(m.spinner.Tick, loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux))
 				}
 				if m.nav.InGitRepo {
-					return m, tea.Batch(m.spinner.Tick, loadDataCmd(m.deps.Svc))
+					return m, tea.Batch(m.spinner.Tick, m.loadDataCmd())
 				}
 				m.nav.GlobalMode = true
 				return m, tea.Batch(m.spinner.Tick, loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux))
@@ -771,11 +1057,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.grid.InAvailable = true
 						m.grid.AvailIdx = 0
 					}
-					return m, m.loadGridContentCmd()
+					return m, tea.Batch(m.loadGridContentCmd(), m.loadGridStatusCmd())
 				case kindWorktree:
 					wt := sel.Data.(workspace.WorktreeState)
 					m.pending.Worktree = &wt
-					m.menu.SetItems(actionMenuItems(wt.HasSession))
+					m.menu.SetItems(actionMenuItems(wt))
 					m.menu.Select(0)
 					m.nav.State = stateActionMenu
 				case kindOrphan:
@@ -790,22 +1076,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.nav.State = stateOrphanMenu
 				case kindRecent:
 					r := sel.Data.(recent.Entry)
-					if !m.deps.Tmux.HasSession(r.SessionName) {
-						if err := m.deps.Tmux.NewSession(r.SessionName, r.Path); err != nil {
-							m.toast = &toast{message: "Failed to create session: " + err.Error(), kind: toastError, expiresAt: time.Now().Add(toastDuration)}
-							return m, toastExpireCmd()
-						}
-					}
-					if m.deps.RecentStore != nil {
-						m.deps.RecentStore.Add(r.RepoRoot, r.Worktree, r.SessionName, r.Path)
-						_ = m.deps.RecentStore.Save()
-					}
-					m.jumpTarget = &JumpTarget{SessionName: r.SessionName, Path: r.Path}
-					return m, tea.Quit
+					return m, m.jumpToWorktree(m.serviceFor(r.RepoRoot), r.Worktree, r.Path)
 				case kindGlobal:
 					wt := sel.Data.(scanner.RepoWorktree)
 					m.pending.Global = &wt
-					m.menu.SetItems(globalActionMenuItems())
+					m.menu.SetItems(globalActionMenuItems(wt.Worktree))
 					m.menu.Select(0)
 					m.nav.State = stateActionMenu
 				}
@@ -817,7 +1092,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if sel, ok := m.list.SelectedItem().(listItem); ok && sel.Kind == kindWorktree {
 				wt := sel.Data.(workspace.WorktreeState)
 				m.pending.Worktree = &wt
-				m.menu.SetItems(actionMenuItems(wt.HasSession))
+				m.menu.SetItems(actionMenuItems(wt))
 				m.menu.Select(0)
 				m.nav.State = stateActionMenu
 			}
@@ -831,11 +1106,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			if sel, ok := m.list.SelectedItem().(listItem); ok && sel.Kind == kindWorktree {
 				wt := sel.Data.(workspace.WorktreeState)
-				if m.deps.Svc.Git != nil && wt.Worktree.Path == m.deps.Svc.Git.RepoRoot {
-					m.toast = &toast{message: "Cannot delete current worktree", kind: toastError, expiresAt: time.Now().Add(toastDuration)}
-					return m, toastExpireCmd()
-				}
-				cmds = append(cmds, deleteWorktreeCmd(m.deps.Svc, wt.Worktree.Path))
+				cmds = append(cmds, m.confirmDelete(wt))
 			}
 		case "ctrl+p":
 			return m, m.openCommandPalette()
@@ -865,7 +1136,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.grid.InAvailable = true
 					m.grid.AvailIdx = 0
 				}
-				return m, m.loadGridContentCmd()
+				return m, tea.Batch(m.loadGridContentCmd(), m.loadGridStatusCmd())
 			} else if m.nav.State == stateGridView {
 				m.nav.State = stateMain
 				return m, nil
@@ -915,7 +1186,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.refreshInFlight--
 				return m, toastExpireCmd()
 			}
-			return m, tea.Batch(loadDataCmd(m.deps.Svc), toastExpireCmd())
+			return m, tea.Batch(m.loadDataCmd(), toastExpireCmd())
 		default:
 			if result, cmd := handleGridFilterInput(&m, msg.String()); result != nil {
 				return result, cmd
@@ -967,6 +1238,9 @@ func (m *model) buildGridPanels() {
 	m.grid.Panels = result.Panels
 	m.grid.Available = result.Available
 	m.grid.Cols = result.Cols
+	if m.nav.GlobalMode {
+		m.applyGridStatus()
+	}
 	m.grid.InvalidateFilterCache()
 
 	for i := range m.grid.Panels {
@@ -980,6 +1254,77 @@ func (m *model) buildGridPanels() {
 	}
 }
 
+// applyGridStatus sets the status of global grid panels from the last
+// gridStatusCmd.
+func (m *model) applyGridStatus() {
+	for _, panels := range [][]views.GridPanel{m.grid.Panels, m.grid.Available} {
+		for i := range panels {
+			if status, ok := m.gridStatus[panels[i].Path]; ok && !panels[i].IsOrphan {
+				panels[i].Status = status
+			}
+		}
+	}
+	m.grid.InvalidateFilterCache()
+}
+
+// loadGridStatusCmd reads the git status of the grid's worktrees in global
+// mode, where the worktree list carries none, with one command per
+// repository.
+func (m *model) loadGridStatusCmd() tea.Cmd {
+	if !m.nav.GlobalMode {
+		return nil
+	}
+	paths := map[string][]string{}
+	for _, panels := range [][]views.GridPanel{m.grid.Panels, m.grid.Available} {
+		for _, p := range panels {
+			if p.Path != "" && p.RepoRoot != "" && !p.IsOrphan {
+				paths[p.RepoRoot] = append(paths[p.RepoRoot], p.Path)
+			}
+		}
+	}
+	var cmds []tea.Cmd
+	for root, repoPaths := range paths {
+		cmds = append(cmds, gridStatusCmd(m.serviceFor(root), repoPaths))
+	}
+	return tea.Batch(cmds...)
+}
+
+// serviceFor returns the workspace service for repoRoot, reusing the
+// launch repo's service when it matches and otherwise the one made for
+// repoRoot before.
+func (m *model) serviceFor(repoRoot string) *workspace.Service {
+	if m.deps.Svc != nil && m.deps.Svc.Git != nil && (repoRoot == "" || m.deps.Svc.Git.RepoRoot == repoRoot) {
+		return m.deps.Svc
+	}
+	if svc, ok := m.services[repoRoot]; ok {
+		return svc
+	}
+	var cmd shell.Commander = m.deps.Tmux.Cmd
+	if cmd == nil {
+		cmd = &shell.ExecCommander{}
+	}
+	svc := workspace.NewServiceForRepo(repoRoot, m.deps.Tmux, m.deps.Cfg, cmd)
+	if m.services != nil {
+		m.services[repoRoot] = svc
+	}
+	return svc
+}
+
+// loadDataCmd reloads the current repo. The recent store is read here
+// rather than in the command, as it is only changed on the Update goroutine.
+func (m *model) loadDataCmd() tea.Cmd {
+	svc := m.deps.Svc
+	var recents []recent.Entry
+	services := map[string]*workspace.Service{}
+	if m.deps.RecentStore != nil && svc != nil && svc.Git != nil {
+		recents = m.deps.RecentStore.GetOtherProjects(svc.Git.RepoRoot, 5)
+		for _, r := range recents {
+			services[r.RepoRoot] = m.serviceFor(r.RepoRoot)
+		}
+	}
+	return loadDataCmd(svc, recents, services)
+}
+
 func (m *model) loadGridContentCmd() tea.Cmd {
 	panels := m.grid.Panels
 	tmux := m.deps.Tmux
@@ -1040,7 +1385,7 @@ func (m *model) commandPaletteItems() []CommandItem {
 				return tea.Batch(m.spinner.Tick, loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux))
 			}
 			if m.nav.InGitRepo {
-				return tea.Batch(m.spinner.Tick, loadDataCmd(m.deps.Svc))
+				return tea.Batch(m.spinner.Tick, m.loadDataCmd())
 			}
 			m.nav.GlobalMode = true
 			return tea.Batch(m.spinner.Tick, loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux))
@@ -1055,7 +1400,15 @@ func (m *model) commandPaletteItems() []CommandItem {
 				m.refreshInFlight--
 				return toastExpireCmd()
 			}
-			return tea.Batch(loadDataCmd(m.deps.Svc), toastExpireCmd())
+			return tea.Batch(m.loadDataCmd(), toastExpireCmd())
+		}},
+		{label: "Prune worktrees", desc: "Remove merged, gone-upstream or stale worktrees", run: func(m *model) tea.Cmd {
+			if m.nav.GlobalMode || m.deps.Svc == nil {
+				m.toast = &toast{message: "Prune works on the current repository", kind: toastWarning, expiresAt: time.Now().Add(toastDuration)}
+				return toastExpireCmd()
+			}
+			m.toast = &toast{message: "Checking worktrees...", kind: toastInfo, expiresAt: time.Now().Add(toastDuration)}
+			return tea.Batch(pruneCandidatesCmd(m.deps.Svc, m.data.States), toastExpireCmd())
 		}},
 		{label: "Show help", desc: "Display keybindings and commands", run: func(m *model) tea.Cmd {
 			m.nav.State = stateHelp
@@ -1078,26 +1431,13 @@ func (m *model) commandPaletteItems() []CommandItem {
 					if m.deps.Svc == nil {
 						return nil
 					}
-					sessionName := m.deps.Svc.SessionName(wt.Worktree.Path)
-					if !m.deps.Svc.Tmux.HasSession(sessionName) {
-						_ = m.deps.Svc.Tmux.NewSession(sessionName, wt.Worktree.Path)
-					}
-					if m.deps.RecentStore != nil && m.deps.Svc.Git != nil {
-						m.deps.RecentStore.Add(m.deps.Svc.Git.RepoRoot, wt.Worktree.Name, sessionName, wt.Worktree.Path)
-						_ = m.deps.RecentStore.Save()
-					}
-					m.jumpTarget = &JumpTarget{SessionName: sessionName, Path: wt.Worktree.Path}
-					return tea.Quit
+					return m.jumpToWorktree(m.deps.Svc, wt.Worktree.Name, wt.Worktree.Path)
 				}},
 				CommandItem{label: "Delete worktree", desc: "Remove worktree and kill session", run: func(m *model) tea.Cmd {
-					if m.deps.Svc == nil || m.deps.Svc.Git == nil {
-						return nil
-					}
-					if wt.Worktree.Path == m.deps.Svc.Git.RepoRoot {
-						m.toast = &toast{message: "Cannot delete current worktree", kind: toastError, expiresAt: time.Now().Add(toastDuration)}
-						return toastExpireCmd()
-					}
-					return deleteWorktreeCmd(m.deps.Svc, wt.Worktree.Path)
+					return m.confirmDelete(wt)
+				}},
+				CommandItem{label: "Rename worktree", desc: "Rename folder, branch and session", run: func(m *model) tea.Cmd {
+					return m.startRename(m.deps.Svc, wt.Worktree, false)
 				}},
 			)
 			if wt.HasSession {
@@ -1108,18 +1448,18 @@ func (m *model) commandPaletteItems() []CommandItem {
 					return killSessionCmd(m.deps.Svc, wt.SessionName)
 				}})
 			}
+			items = append(items, worktreeAdminCommands(m.deps.Svc, wt.Worktree)...)
 		case kindGlobal:
 			wt := sel.Data.(scanner.RepoWorktree)
-			sessionName := wt.Worktree.Name
 			items = append(items,
 				CommandItem{label: "Jump to worktree", desc: "Switch to selected worktree session", run: func(m *model) tea.Cmd {
-					if !m.deps.Tmux.HasSession(sessionName) {
-						_ = m.deps.Tmux.NewSession(sessionName, wt.Worktree.Path)
-					}
-					m.jumpTarget = &JumpTarget{SessionName: sessionName, Path: wt.Worktree.Path}
-					return tea.Quit
+					return m.jumpToWorktree(m.serviceFor(wt.RepoRoot), wt.Worktree.Name, wt.Worktree.Path)
+				}},
+				CommandItem{label: "Rename worktree", desc: "Rename folder, branch and session", run: func(m *model) tea.Cmd {
+					return m.startRename(m.serviceFor(wt.RepoRoot), wt.Worktree, true)
 				}},
 			)
+			items = append(items, worktreeAdminCommands(m.serviceFor(wt.RepoRoot), wt.Worktree)...)
 		case kindOrphan:
 			sessionName := sel.ItemTitle
 			items = append(items, CommandItem{label: "Kill orphan session", desc: "Kill this orphaned session", run: func(m *model) tea.Cmd {
@@ -1137,6 +1477,30 @@ func (m *model) commandPaletteItems() []CommandItem {
 	return items
 }
 
+// worktreeAdminCommands are the palette entries for git worktree lock,
+// unlock, repair and prune on wt.
+func worktreeAdminCommands(svc *workspace.Service, wt git.Worktree) []CommandItem {
+	var items []CommandItem
+	if wt.Locked {
+		items = append(items, CommandItem{label: "Unlock worktree", desc: "Allow prune, move and remove again", run: func(m *model) tea.Cmd {
+			return unlockWorktreeCmd(svc, wt.Path)
+		}})
+	} else {
+		items = append(items, CommandItem{label: "Lock worktree", desc: "Protect from prune, move and remove", run: func(m *model) tea.Cmd {
+			return m.startLock(svc, wt)
+		}})
+	}
+	items = append(items, CommandItem{label: "Repair worktree", desc: "Fix links after moving by hand", run: func(m *model) tea.Cmd {
+		return repairWorktreeCmd(svc, wt.Path)
+	}})
+	if wt.Prunable {
+		items = append(items, CommandItem{label: "Prune stale worktree entries", desc: "Forget worktrees whose directory is gone", run: func(m *model) tea.Cmd {
+			return pruneStaleCmd(svc)
+		}})
+	}
+	return items
+}
+
 // View
 
 func (m model) View() string {
@@ -1156,6 +1520,12 @@ func (m model) View() string {
 		return views.RenderRepoSelector(&m.menu)
 	case stateCreateName:
 		return views.RenderNameInput(m.input.View())
+	case stateRenameName:
+		return views.RenderPrompt("Rename "+m.pending.Rename.name, "New name:", m.input.View())
+	case stateRenameBranch:
+		return views.RenderMenu("Rename "+m.pending.Rename.name, &m.menu)
+	case stateLockReason:
+		return views.RenderPrompt("Lock "+m.pending.Lock.name, "Reason (optional):", m.input.View())
 	case stateCreateBranch:
 		return views.RenderBranchSelector(&m.menu)
 	case stateOrphanBranch:
@@ -1164,6 +1534,12 @@ func (m model) View() string {
 		return views.RenderMenu("Actions", &m.menu)
 	case stateOrphanMenu:
 		return views.RenderMenu("Orphaned session", &m.menu)
+	case statePruneSelect:
+		return views.RenderConfirm("Prune worktrees", theme.DimStyle.Render("space toggle · enter continue · esc cancel"), &m.menu)
+	case statePruneConfirm:
+		return views.RenderConfirm("Prune worktrees", pruneSummary(m.pending.Prune), &m.menu)
+	case stateConfirmDelete:
+		return views.RenderConfirm("Delete "+m.pending.Worktree.Worktree.Name, branchStatusLine(m.pending.Branch), &m.menu)
 	case stateCommandPalette:
 		return renderCommandPalette(&m.commandPalette, m.width, m.height)
 	case stateGridView:
@@ -1203,7 +1579,7 @@ func (m model) View() string {
 	if m.nav.GlobalMode {
 		repoIndicator = theme.WarnStyle.Render("GLOBAL")
 	} else if m.deps.Svc != nil && m.deps.Svc.Git != nil {
-		repoName := filepath.Base(m.deps.Svc.Git.RepoRoot)
+		repoName := git.RepoName(m.deps.Svc.Git.RepoRoot)
 		repoIndicator = theme.SectionStyle.Render(repoName)
 	}
 
@@ -1380,17 +1756,98 @@ type key struct {
 	d string
 }
 
-func actionMenuItems(hasSession bool) []list.Item {
+func actionMenuItems(wt workspace.WorktreeState) []list.Item {
 	items := []list.Item{
 		listItem{ItemTitle: theme.IconJump + "  Jump", ItemDesc: "Switch to session", Kind: kindHeader},
 		listItem{ItemTitle: theme.IconDelete + "  Delete worktree", ItemDesc: "Delete worktree + session", Kind: kindHeader},
 	}
-	if hasSession {
+	items = append(items, listItem{ItemTitle: theme.IconRename + "  Rename", ItemDesc: "Rename folder, branch and session", Kind: kindHeader})
+	if wt.HasSession {
 		items = append(items, listItem{ItemTitle: theme.IconKill + "  Kill session", ItemDesc: "Kill tmux session only", Kind: kindHeader})
 	}
+	return append(items, worktreeAdminMenuItems(wt.Worktree)...)
+}
+
+// worktreeAdminMenuItems are the git worktree lock, unlock, repair and prune
+// actions that apply to wt.
+func worktreeAdminMenuItems(wt git.Worktree) []list.Item {
+	var items []list.Item
+	if wt.Locked {
+		items = append(items, listItem{ItemTitle: theme.IconLock + "  Unlock", ItemDesc: "Allow prune, move and remove again", Kind: kindHeader})
+	} else {
+		items = append(items, listItem{ItemTitle: theme.IconLock + "  Lock", ItemDesc: "Protect from prune, move and remove", Kind: kindHeader})
+	}
+	items = append(items, listItem{ItemTitle: theme.IconRepair + "  Repair", ItemDesc: "Fix links after moving by hand", Kind: kindHeader})
+	if wt.Prunable {
+		items = append(items, listItem{ItemTitle: theme.IconDelete + "  Prune stale entries", ItemDesc: "Forget worktrees whose directory is gone", Kind: kindHeader})
+	}
 	return items
 }
 
+func deleteMenuItems(b *workspace.BranchInfo) []list.Item {
+	items := []list.Item{
+		listItem{ItemTitle: theme.IconDelete + "  Delete, keep branch", ItemDesc: "Remove worktree + session only", Kind: kindHeader},
+	}
+	if b.Name != "" && b.Name != b.Base {
+		if b.Merged {
+			items = append(items, listItem{ItemTitle: theme.IconDelete + "  Delete + branch", ItemDesc: "Also delete " + b.Name, Kind: kindHeader})
+		} else {
+			items = append(items, listItem{ItemTitle: theme.IconDelete + "  Delete + force delete branch", ItemDesc: "Unmerged commits on " + b.Name + " are lost", Kind: kindHeader})
+		}
+		if b.Remote != "" {
+			label := "  Delete + branch + remote"
+			if !b.Merged {
+				label = "  Delete + force delete branch + remote"
+			}
+			items = append(items, listItem{ItemTitle: theme.IconDelete + label, ItemDesc: "Also delete " + b.Remote + "/" + b.RemoteBranch, Kind: kindHeader})
+		}
+	}
+	return append(items, listItem{ItemTitle: "   Cancel", ItemDesc: "Keep everything", Kind: kindHeader})
+}
+
+func pruneMenuItems(entries []pruneEntry) []list.Item {
+	items := make([]list.Item, len(entries))
+	for i, e := range entries {
+		mark := "[ ] "
+		if e.selected {
+			mark = "[x] "
+		}
+		c := e.candidate
+		desc := string(c.Reason)
+		if c.Branch.Name != "" {
+			desc += " · " + theme.IconBranch + " " + c.Branch.Name
+		}
+		if !c.LastActivity.IsZero() {
+			desc += " · " + formatDuration(time.Since(c.LastActivity)) + " ago"
+		}
+		items[i] = listItem{ItemTitle: mark + c.State.Worktree.Name, ItemDesc: desc, Kind: kindHeader}
+	}
+	return items
+}
+
+func pruneSummary(entries []pruneEntry) string {
+	var names []string
+	for _, e := range entries {
+		if e.selected {
+			names = append(names, e.candidate.State.Worktree.Name)
+		}
+	}
+	return theme.WarnStyle.Render(fmt.Sprintf("Removes worktree, session and branch of %d worktree(s):", len(names))) +
+		"\n" + theme.TextStyle.Render(strings.Join(names, ", "))
+}
+
+func branchStatusLine(b *workspace.BranchInfo) string {
+	switch {
+	case b.Name == "":
+		return theme.DimStyle.Render("Detached HEAD, no branch to delete")
+	case b.Name == b.Base:
+		return theme.DimStyle.Render(theme.IconBranch + " " + b.Name + " is the base branch and is kept")
+	case b.Merged:
+		return theme.SuccessStyle.Render(theme.IconBranch + " " + b.Name + " is merged into " + b.Base)
+	}
+	return theme.WarnStyle.Render(theme.IconBranch + " " + b.Name + " is not merged into " + b.Base)
+}
+
 func orphanMenuItems() []list.Item {
 	return []list.Item{
 		listItem{ItemTitle: theme.IconJump + "  Jump", ItemDesc: "Switch to session", Kind: kindHeader},
@@ -1399,9 +1856,19 @@ func orphanMenuItems() []list.Item {
 	}
 }
 
-func globalActionMenuItems() []list.Item {
-	return []list.Item{
+func globalActionMenuItems(wt git.Worktree) []list.Item {
+	items := []list.Item{
 		listItem{ItemTitle: theme.IconJump + "  Jump", ItemDesc: "Switch to session", Kind: kindHeader},
+		listItem{ItemTitle: theme.IconRename + "  Rename", ItemDesc: "Rename folder, branch and session", Kind: kindHeader},
+	}
+	return append(items, worktreeAdminMenuItems(wt)...)
+}
+
+func renameBranchMenuItems(branch, newName string) []list.Item {
+	return []list.Item{
+		listItem{ItemTitle: theme.IconRename + "  Rename branch too", ItemDesc: branch + " → " + newName, Kind: kindHeader},
+		listItem{ItemTitle: theme.IconRename + "  Keep branch", ItemDesc: "Only rename the folder and session", Kind: kindHeader},
+		listItem{ItemTitle: "   Cancel", ItemDesc: "Leave the worktree as it is", Kind: kindHeader},
 	}
 }
 
@@ -1493,22 +1960,12 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	case kindWorktree:
 		wt := i.Data.(workspace.WorktreeState)
 		name := wt.Worktree.Name
-		branch := wt.Worktree.Branch
+		branch := views.BranchLabel(wt.Worktree)
 
-		statusBadge := theme.SuccessStyle.Render(theme.IconClean)
-		if wt.Status != nil && !wt.Status.Clean {
-			badgeParts := []string{}
-			if wt.Status.Modified > 0 {
-				badgeParts = append(badgeParts, theme.WarnStyle.Render(fmt.Sprintf("%dM", wt.Status.Modified)))
-			}
-			if wt.Status.Staged > 0 {
-				badgeParts = append(badgeParts, theme.SectionStyle.Render(fmt.Sprintf("%dS", wt.Status.Staged)))
-			}
-			if len(badgeParts) > 0 {
-				statusBadge = strings.Join(badgeParts, " ")
-			} else if wt.Status.Untracked > 0 {
-				statusBadge = theme.DimStyle.Render(fmt.Sprintf("%d?", wt.Status.Untracked))
-			}
+		statusBadge := views.StatusBadge(wt.Status)
+		badges := views.WorktreeBadges(wt.Worktree.Locked, wt.Worktree.Prunable)
+		if badges != "" {
+			statusBadge += " " + badges
 		}
 
 		indicator := " "
@@ -1527,6 +1984,9 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 
 		nameDisplay := name
 		maxNameWidth := width - 14
+		if badges != "" {
+			maxNameWidth -= lipgloss.Width(badges) + 1
+		}
 		if maxNameWidth < 10 {
 			maxNameWidth = 10
 		}
@@ -1615,10 +2075,14 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	case kindGlobal:
 		wt := i.Data.(scanner.RepoWorktree)
 		name := wt.Worktree.Name
-		branch := wt.Worktree.Branch
+		branch := views.BranchLabel(wt.Worktree)
+		badges := views.WorktreeBadges(wt.Worktree.Locked, wt.Worktree.Prunable)
 
 		nameDisplay := name
 		maxNameWidth := width - 8
+		if badges != "" {
+			maxNameWidth -= lipgloss.Width(badges) + 2
+		}
 		if maxNameWidth < 10 {
 			maxNameWidth = 10
 		}
@@ -1643,6 +2107,9 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 			line1 = accentBar + theme.TextStyle.Render(theme.IconWorktree+" "+nameDisplay)
 			line2 = accentBar + "    " + theme.BranchStyle.Render(theme.IconBranch+" "+branchDisplay)
 		}
+		if badges != "" {
+			line1 += "  " + badges
+		}
 	}
 
 	if selected && i.Kind != kindSeparator && i.Kind != kindHeader && i.Kind != kindRepoHeader {
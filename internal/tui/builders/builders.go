@@ -130,7 +130,7 @@ func BuildGlobalItems(worktrees []scanner.RepoWorktree, orphans []string, tmux T
 
 	var withSession, withoutSession []scanner.RepoWorktree
 	for _, wt := range worktrees {
-		if tmux.HasSession(wt.Worktree.Name) {
+		if tmux.HasSession(wt.SessionName) {
 			withSession = append(withSession, wt)
 		} else {
 			withoutSession = append(withoutSession, wt)
@@ -142,7 +142,7 @@ func BuildGlobalItems(worktrees []scanner.RepoWorktree, orphans []string, tmux T
 		for _, wt := range withSession {
 			items = append(items, ListItem{
 				ItemTitle: wt.RepoName + "/" + wt.Worktree.Name,
-				ItemDesc:  wt.Worktree.Branch,
+				ItemDesc:  views.BranchLabel(wt.Worktree),
 				Kind:      KindGlobal,
 				Data:      wt,
 			})
@@ -157,7 +157,7 @@ func BuildGlobalItems(worktrees []scanner.RepoWorktree, orphans []string, tmux T
 		for _, wt := range withoutSession {
 			items = append(items, ListItem{
 				ItemTitle: wt.RepoName + "/" + wt.Worktree.Name,
-				ItemDesc:  wt.Worktree.Branch,
+				ItemDesc:  views.BranchLabel(wt.Worktree),
 				Kind:      KindGlobal,
 				Data:      wt,
 			})
@@ -198,21 +198,28 @@ func BuildGridPanels(in GridBuildInput) GridBuildOutput {
 
 	if in.GlobalMode {
 		for _, wt := range in.GlobalWorktrees {
-			sessionName := wt.Worktree.Name
+			sessionName := wt.SessionName
 			if in.Tmux.HasSession(sessionName) {
 				panels = append(panels, views.GridPanel{
 					Name:        wt.RepoName + "/" + wt.Worktree.Name,
 					SessionName: sessionName,
 					Path:        wt.Worktree.Path,
-					Branch:      wt.Worktree.Branch,
+					RepoRoot:    wt.RepoRoot,
+					Branch:      views.BranchLabel(wt.Worktree),
+					Locked:      wt.Worktree.Locked,
+					Prunable:    wt.Worktree.Prunable,
 					HasSession:  true,
 				})
 			} else {
 				available = append(available, views.GridPanel{
-					Name:       wt.RepoName + "/" + wt.Worktree.Name,
-					Path:       wt.Worktree.Path,
-					Branch:     wt.Worktree.Branch,
-					HasSession: false,
+					Name:        wt.RepoName + "/" + wt.Worktree.Name,
+					SessionName: sessionName,
+					Path:        wt.Worktree.Path,
+					RepoRoot:    wt.RepoRoot,
+					Branch:      views.BranchLabel(wt.Worktree),
+					Locked:      wt.Worktree.Locked,
+					Prunable:    wt.Worktree.Prunable,
+					HasSession:  false,
 				})
 			}
 		}
@@ -223,13 +230,12 @@ func BuildGridPanels(in GridBuildInput) GridBuildOutput {
 					Name:        st.Worktree.Name,
 					SessionName: st.SessionName,
 					Path:        st.Worktree.Path,
-					Branch:      st.Worktree.Branch,
+					Branch:      views.BranchLabel(st.Worktree),
+					Locked:      st.Worktree.Locked,
+					Prunable:    st.Worktree.Prunable,
+					Status:      st.Status,
 					HasSession:  true,
 				}
-				if st.Status != nil {
-					panel.Modified = st.Status.Modified
-					panel.Staged = st.Status.Staged
-				}
 				if st.SessionInfo != nil {
 					panel.Windows = st.SessionInfo.Windows
 					panel.Panes = st.SessionInfo.Panes
@@ -238,14 +244,14 @@ func BuildGridPanels(in GridBuildInput) GridBuildOutput {
 				panels = append(panels, panel)
 			} else {
 				panel := views.GridPanel{
-					Name:       st.Worktree.Name,
-					Path:       st.Worktree.Path,
-					Branch:     st.Worktree.Branch,
-					HasSession: false,
-				}
-				if st.Status != nil {
-					panel.Modified = st.Status.Modified
-					panel.Staged = st.Status.Staged
+					Name:        st.Worktree.Name,
+					SessionName: st.SessionName,
+					Path:        st.Worktree.Path,
+					Branch:      views.BranchLabel(st.Worktree),
+					Locked:      st.Worktree.Locked,
+					Prunable:    st.Worktree.Prunable,
+					Status:      st.Status,
+					HasSession:  false,
 				}
 				available = append(available, panel)
 			}
@@ -274,6 +280,7 @@ func BuildGridPanels(in GridBuildInput) GridBuildOutput {
 				Name:        r.RepoName + "/" + r.Worktree,
 				SessionName: sessionName,
 				Path:        r.Path,
+				RepoRoot:    r.RepoRoot,
 				HasSession:  in.Tmux.HasSession(sessionName),
 				IsRecent:    true,
 			})
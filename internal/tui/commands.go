@@ -1,17 +1,25 @@
 package tui
 
 import (
+	"errors"
+	"os"
 	"sort"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/nicobailon/treemux/internal/config"
+	"github.com/nicobailon/treemux/internal/git"
 	"github.com/nicobailon/treemux/internal/recent"
 	"github.com/nicobailon/treemux/internal/scanner"
+	"github.com/nicobailon/treemux/internal/shell"
 	"github.com/nicobailon/treemux/internal/tmux"
 	"github.com/nicobailon/treemux/internal/tui/views"
 	"github.com/nicobailon/treemux/internal/workspace"
 )
 
+// jumpMsg ends the TUI on a session. repoRoot and worktree, when set, are
+// recorded in the recent store.
 type jumpMsg struct {
 	sessionName string
 	path        string
@@ -26,35 +34,129 @@ func loadPaneContentCmd(t *tmux.Tmux, sessionName string, lines int) tea.Cmd {
 	}
 }
 
-func loadDataCmd(svc *workspace.Service) tea.Cmd {
+// loadDataCmd lists the worktrees of svc's repo and resolves the session
+// names of recents, the recent worktrees of other projects, with the
+// services of their repos.
+func loadDataCmd(svc *workspace.Service, recents []recent.Entry, services map[string]*workspace.Service) tea.Cmd {
 	return func() tea.Msg {
 		states, orphans, err := svc.List()
 		if err != nil {
 			return resultMsg{action: "load", err: err}
 		}
-		return dataLoadedMsg{states: states, orphans: orphans}
+		msg := dataLoadedMsg{states: states, orphans: orphans, recents: recents}
+		if len(recents) > 0 {
+			snap := svc.Tmux.Snapshot()
+			for i, r := range recents {
+				msg.recents[i].SessionName, _, _ = services[r.RepoRoot].SessionIn(snap, r.Path)
+			}
+		}
+		return msg
 	}
 }
 
+func saveSessionsCmd(svc *workspace.Service, states []workspace.WorktreeState) tea.Cmd {
+	return func() tea.Msg {
+		_ = svc.SaveSessions(states)
+		return nil
+	}
+}
+
+// indexTTL is how old the repo index may get before global mode rescans the
+// search paths in the background.
+const indexTTL = 5 * time.Minute
+
+// loadGlobalDataCmd lists worktrees from the repo index, re-listing only the
+// repos whose git metadata changed. The index is built first when there is
+// none for the current search paths.
 func loadGlobalDataCmd(cfg *config.Config, t *tmux.Tmux) tea.Cmd {
 	return func() tea.Msg {
-		worktrees := scanner.ScanAll(cfg.SearchPaths)
-		sessions, _ := t.ListSessions()
-		sessionSet := make(map[string]bool)
-		for _, s := range sessions {
-			sessionSet[s.Name] = true
-		}
-		wtNames := make(map[string]bool)
-		for _, wt := range worktrees {
-			wtNames[wt.Worktree.Name] = true
+		opts := scanner.OptionsFor(cfg.Scan)
+		ix, err := scanner.LoadIndex()
+		if err != nil {
+			return globalData(cfg, t, scanner.ScanAll(cfg.SearchPaths, opts))
 		}
-		var orphans []string
-		for name := range sessionSet {
-			if !wtNames[name] {
-				orphans = append(orphans, name)
+		rescan := false
+		if ix.Matches(cfg.SearchPaths, opts) {
+			if ix.Refresh() {
+				_ = ix.Save()
 			}
+			rescan = time.Since(ix.ScannedAt) > indexTTL
+		} else {
+			ix.Rebuild(cfg.SearchPaths, opts)
+			_ = ix.Save()
 		}
-		return globalDataLoadedMsg{worktrees: worktrees, orphans: orphans}
+		msg := globalData(cfg, t, ix.Worktrees())
+		msg.rescan = rescan
+		return msg
+	}
+}
+
+// rescanIndexCmd rebuilds the repo index to pick up repositories added or
+// removed under the search paths.
+func rescanIndexCmd(cfg *config.Config, t *tmux.Tmux) tea.Cmd {
+	return func() tea.Msg {
+		opts := scanner.OptionsFor(cfg.Scan)
+		var msg globalDataLoadedMsg
+		if ix, err := scanner.LoadIndex(); err == nil {
+			ix.Rebuild(cfg.SearchPaths, opts)
+			_ = ix.Save()
+			msg = globalData(cfg, t, ix.Worktrees())
+		} else {
+			msg = globalData(cfg, t, scanner.ScanAll(cfg.SearchPaths, opts))
+		}
+		msg.rescanned = true
+		return msg
+	}
+}
+
+func globalData(cfg *config.Config, t *tmux.Tmux, worktrees []scanner.RepoWorktree) globalDataLoadedMsg {
+	var cmd shell.Commander = t.Cmd
+	if cmd == nil {
+		cmd = &shell.ExecCommander{}
+	}
+	snap := t.Snapshot()
+	services := map[string]*workspace.Service{}
+	owners := map[string][]string{}
+	claimed := map[string]bool{}
+	repos := map[string]bool{}
+	for i, wt := range worktrees {
+		svc, ok := services[wt.RepoRoot]
+		if !ok {
+			svc = workspace.NewServiceForRepo(wt.RepoRoot, t, cfg, cmd)
+			services[wt.RepoRoot] = svc
+		}
+		name, running := svc.MatchSession(snap, wt.Worktree)
+		worktrees[i].SessionName = name
+		owners[name] = append(owners[name], wt.RepoName+"/"+wt.Worktree.Name)
+		claimed[name] = claimed[name] || running
+		repos[wt.RepoRoot] = true
+	}
+	orphans := workspace.Orphans(snap, claimed, repos)
+	return globalDataLoadedMsg{worktrees: worktrees, orphans: orphans, collisions: sessionCollisions(owners)}
+}
+
+// sessionCollisions describes session names shared by several worktrees,
+// which would otherwise end up in one tmux session.
+func sessionCollisions(owners map[string][]string) []string {
+	var collisions []string
+	for name, wts := range owners {
+		if len(wts) > 1 {
+			collisions = append(collisions, name+" ("+strings.Join(wts, ", ")+")")
+		}
+	}
+	sort.Strings(collisions)
+	return collisions
+}
+
+type gridStatusMsg struct {
+	statuses map[string]*git.StatusSummary
+}
+
+// gridStatusCmd reads the git status of the worktrees at paths, which
+// belong to svc's repository.
+func gridStatusCmd(svc *workspace.Service, paths []string) tea.Cmd {
+	return func() tea.Msg {
+		return gridStatusMsg{statuses: svc.Statuses(paths)}
 	}
 }
 
@@ -69,21 +171,98 @@ func branchesCmd(svc *workspace.Service) tea.Cmd {
 		if def != "" {
 			branches = append([]string{def}, views.FilterStrings(branches, def)...)
 		}
-		return branchesMsg{branches: branches}
+		remotes, _ := svc.Git.RemoteBranches()
+		sort.Strings(remotes)
+		return branchesMsg{branches: branches, remotes: remotes}
+	}
+}
+
+func createWorktreeCmd(svc *workspace.Service, name string, opts workspace.CreateOptions) tea.Cmd {
+	return func() tea.Msg {
+		_, _, err := svc.Create(name, opts)
+		msg := resultMsg{action: "create", err: err}
+		if svc.ConfigWarning != nil {
+			msg.warning = svc.ConfigWarning.Error()
+		}
+		return msg
 	}
 }
 
-func createWorktreeCmd(svc *workspace.Service, name, branch string) tea.Cmd {
+func deleteWorktreeCmd(svc *workspace.Service, path string, opts workspace.DeleteOptions) tea.Cmd {
 	return func() tea.Msg {
-		_, err := svc.CreateWorktree(name, branch)
-		return resultMsg{action: "create", err: err}
+		result, err := svc.DeleteWorktree(path, opts)
+		msg := resultMsg{action: "delete", err: err}
+		if result != nil && result.KeptReason != "" {
+			msg.warning = "Worktree deleted; kept branch: " + result.KeptReason
+		}
+		return msg
 	}
 }
 
-func deleteWorktreeCmd(svc *workspace.Service, path string) tea.Cmd {
+func renameCmd(svc *workspace.Service, path, newName string, opts workspace.RenameOptions) tea.Cmd {
 	return func() tea.Msg {
-		err := svc.DeleteWorktree(path, true)
-		return resultMsg{action: "delete", err: err}
+		result, err := svc.Rename(path, newName, opts)
+		return resultMsg{action: "rename", err: err, renamed: result}
+	}
+}
+
+func pruneCandidatesCmd(svc *workspace.Service, states []workspace.WorktreeState) tea.Cmd {
+	return func() tea.Msg {
+		return pruneCandidatesMsg{candidates: svc.Classify(states, workspace.DefaultStaleAfter)}
+	}
+}
+
+func pruneWorktreesCmd(svc *workspace.Service, candidates []workspace.PruneCandidate) tea.Cmd {
+	return func() tea.Msg {
+		var failed, kept []string
+		for _, c := range candidates {
+			result, err := svc.Prune(c, false)
+			if err != nil {
+				failed = append(failed, c.State.Worktree.Name+": "+errorMessage(err))
+			} else if result.KeptReason != "" {
+				kept = append(kept, result.KeptReason)
+			}
+		}
+		if len(failed) > 0 {
+			return resultMsg{action: "prune", err: errors.New("prune failed for " + strings.Join(failed, "; "))}
+		}
+		msg := resultMsg{action: "prune"}
+		if len(kept) > 0 {
+			msg.warning = "Worktrees pruned; kept branch: " + strings.Join(kept, "; ")
+		}
+		return msg
+	}
+}
+
+func lockWorktreeCmd(svc *workspace.Service, path, reason string) tea.Cmd {
+	return func() tea.Msg {
+		return resultMsg{action: "lock", err: svc.Git.WorktreeLock(path, reason)}
+	}
+}
+
+func unlockWorktreeCmd(svc *workspace.Service, path string) tea.Cmd {
+	return func() tea.Msg {
+		return resultMsg{action: "unlock", err: svc.Git.WorktreeUnlock(path)}
+	}
+}
+
+// repairWorktreeCmd repairs the links of the repository's worktrees, and of
+// the one at path when its directory is still there.
+func repairWorktreeCmd(svc *workspace.Service, path string) tea.Cmd {
+	return func() tea.Msg {
+		var paths []string
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+		return resultMsg{action: "repair", err: svc.Git.WorktreeRepair(paths...)}
+	}
+}
+
+// pruneStaleCmd runs git worktree prune, which forgets worktrees whose
+// directory is gone. Unlike pruneWorktreesCmd it never deletes files.
+func pruneStaleCmd(svc *workspace.Service) tea.Cmd {
+	return func() tea.Msg {
+		return resultMsg{action: "prune-stale", err: svc.Git.WorktreePrune()}
 	}
 }
 
@@ -97,33 +276,28 @@ func killSessionCmd(svc *workspace.Service, name string) tea.Cmd {
 func killSessionDirectCmd(t *tmux.Tmux, name string) tea.Cmd {
 	return func() tea.Msg {
 		err := t.KillSession(name)
+		if errors.Is(err, tmux.ErrSessionNotFound) {
+			// Gone already, which is what was asked for.
+			err = nil
+		}
 		return resultMsg{action: "kill-session", err: err}
 	}
 }
 
-func jumpCmd(svc *workspace.Service, name, path string, store *recent.Store) tea.Cmd {
+func jumpCmd(svc *workspace.Service, name, path string) tea.Cmd {
 	return func() tea.Msg {
-		sessionName := svc.SessionName(path)
-		if !svc.Tmux.HasSession(sessionName) {
-			if err := svc.Tmux.NewSession(sessionName, path); err != nil {
-				return resultMsg{action: "jump", err: err}
-			}
-		}
-		if store != nil {
-			store.Add(svc.Git.RepoRoot, name, sessionName, path)
-			_ = store.Save()
+		sessionName, err := svc.PrepareJump(path)
+		if err != nil {
+			return resultMsg{action: "jump", err: err}
 		}
 		return jumpMsg{sessionName: sessionName, path: path, repoRoot: svc.Git.RepoRoot, worktree: name}
 	}
 }
 
-func switchRecentCmd(svc *workspace.Service, entry recent.Entry, store *recent.Store) tea.Cmd {
+func switchRecentCmd(svc *workspace.Service, entry recent.Entry) tea.Cmd {
 	return func() tea.Msg {
-		if store != nil {
-			store.Add(entry.RepoRoot, entry.Worktree, entry.SessionName, entry.Path)
-			_ = store.Save()
-		}
-		return jumpMsg{sessionName: entry.SessionName, path: entry.Path, repoRoot: entry.RepoRoot, worktree: entry.Worktree}
+		sessionName, _, _ := svc.SessionFor(entry.Path)
+		return jumpMsg{sessionName: sessionName, path: entry.Path, repoRoot: entry.RepoRoot, worktree: entry.Worktree}
 	}
 }
 
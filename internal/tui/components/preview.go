@@ -5,10 +5,12 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/nicobailon/treemux/internal/git"
 	"github.com/nicobailon/treemux/internal/recent"
 	"github.com/nicobailon/treemux/internal/scanner"
 	"github.com/nicobailon/treemux/internal/tmux"
 	"github.com/nicobailon/treemux/internal/tui/theme"
+	"github.com/nicobailon/treemux/internal/tui/views"
 	"github.com/nicobailon/treemux/internal/workspace"
 )
 
@@ -309,38 +311,27 @@ func renderWorktreePreview(wt workspace.WorktreeState, width int) string {
 		if wt.Status.Clean {
 			statusText = theme.SuccessStyle.Render(theme.IconClean + " clean")
 		} else {
-			parts := []string{}
-			if wt.Status.Modified > 0 {
-				parts = append(parts, theme.WarnStyle.Render(fmt.Sprintf("%d modified", wt.Status.Modified)))
-			}
-			if wt.Status.Staged > 0 {
-				parts = append(parts, theme.SectionStyle.Render(fmt.Sprintf("%d staged", wt.Status.Staged)))
-			}
-			if wt.Status.Untracked > 0 {
-				parts = append(parts, theme.DimStyle.Render(fmt.Sprintf("%d untracked", wt.Status.Untracked)))
-			}
-			statusText = strings.Join(parts, ", ")
+			statusText = strings.Join(views.StatusCounts(wt.Status), ", ")
 		}
 	}
 
 	statusLines := []string{
-		kvLine("Branch", theme.TextStyle.Render(wt.Worktree.Branch)),
+		kvLine("Branch", theme.TextStyle.Render(views.BranchLabel(wt.Worktree))),
 		kvLine("Status", statusText),
 		kvLine("Path", theme.SubTextStyle.Render(pathDisplay)),
 	}
+	statusLines = append(statusLines, worktreeStateLines(wt.Worktree)...)
 
-	if wt.Ahead > 0 || wt.Behind > 0 {
-		sync := ""
-		if wt.Ahead > 0 {
-			sync += theme.SuccessStyle.Render(fmt.Sprintf("%d ahead", wt.Ahead))
+	if wt.Status != nil {
+		if sync := views.SyncLabel(wt.Status); sync != "" {
+			statusLines = append(statusLines, kvLine("Sync", sync))
 		}
-		if wt.Behind > 0 {
-			if sync != "" {
-				sync += ", "
-			}
-			sync += theme.WarnStyle.Render(fmt.Sprintf("%d behind", wt.Behind))
+		if wt.Status.Stashes > 0 {
+			statusLines = append(statusLines, kvLine("Stashes", theme.TextStyle.Render(fmt.Sprint(wt.Status.Stashes))))
+		}
+		if wt.Status.Operation != "" {
+			statusLines = append(statusLines, kvLine("State", theme.ErrorStyle.Render(wt.Status.Operation+" in progress")))
 		}
-		statusLines = append(statusLines, kvLine("Sync", sync))
 	}
 
 	statusCard := renderCard(theme.IconBranch+" Status", strings.Join(statusLines, "\n"), width)
@@ -385,6 +376,27 @@ func renderWorktreePreview(wt workspace.WorktreeState, width int) string {
 	return strings.Join(sections, "\n")
 }
 
+// worktreeStateLines describes a locked or prunable worktree with the
+// reason git recorded.
+func worktreeStateLines(wt git.Worktree) []string {
+	var lines []string
+	if wt.Locked {
+		reason := wt.LockReason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		lines = append(lines, kvLine("Locked", theme.WarnStyle.Render(reason)))
+	}
+	if wt.Prunable {
+		reason := wt.PrunableReason
+		if reason == "" {
+			reason = "directory is gone"
+		}
+		lines = append(lines, kvLine("Stale", theme.ErrorStyle.Render(reason)))
+	}
+	return lines
+}
+
 func renderGlobalPreview(wt scanner.RepoWorktree, ctx PreviewContext) string {
 	maxW := ctx.Width - 12
 	if maxW < 20 {
@@ -398,16 +410,17 @@ func renderGlobalPreview(wt scanner.RepoWorktree, ctx PreviewContext) string {
 		pathDisplay = "..." + pathDisplay[len(pathDisplay)-maxW+3:]
 	}
 
-	hasSession := ctx.Tmux.HasSession(wt.Worktree.Name)
+	hasSession := ctx.Tmux.HasSession(wt.SessionName)
 
 	statusLines := []string{
-		kvLine("Branch", theme.TextStyle.Render(wt.Worktree.Branch)),
+		kvLine("Branch", theme.TextStyle.Render(views.BranchLabel(wt.Worktree))),
 		kvLine("Path", theme.SubTextStyle.Render(pathDisplay)),
 	}
+	statusLines = append(statusLines, worktreeStateLines(wt.Worktree)...)
 
 	if hasSession {
 		statusLines = append(statusLines, kvLine("Session", theme.SuccessStyle.Render("● active")))
-		if info, err := ctx.Tmux.SessionInfo(wt.Worktree.Name); err == nil && info != nil {
+		if info, err := ctx.Tmux.SessionInfo(wt.SessionName); err == nil && info != nil {
 			sessionInfo := fmt.Sprintf("%d windows, %d panes", info.Windows, info.Panes)
 			statusLines = append(statusLines, kvLine("", theme.TextStyle.Render(sessionInfo)))
 		}
@@ -448,7 +461,7 @@ func renderGridViewPreview(ctx PreviewContext) string {
 	sessionCount := 0
 	if ctx.GlobalMode {
 		for _, wt := range ctx.GlobalWorktrees {
-			if ctx.Tmux.HasSession(wt.Worktree.Name) {
+			if ctx.Tmux.HasSession(wt.SessionName) {
 				sessionCount++
 			}
 		}
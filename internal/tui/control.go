@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+// startControlCmd attaches a control-mode client to the first session on the
+// server. Without sessions there is nothing to watch and the TUI keeps
+// polling until the next refresh tick tries again.
+func startControlCmd(t *tmux.Tmux) tea.Cmd {
+	return func() tea.Msg {
+		names := t.Snapshot().Names
+		if len(names) == 0 {
+			return controlStartedMsg{}
+		}
+		client, err := t.StartControl(names[0])
+		return controlStartedMsg{client: client, session: names[0], err: err}
+	}
+}
+
+func waitForControlEvent(c *tmux.ControlClient) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-c.Events()
+		return controlEventMsg{event: ev, ok: ok}
+	}
+}
+
+func (m model) handleControlStarted(msg controlStartedMsg) (tea.Model, tea.Cmd) {
+	m.controlStarting = false
+	if msg.err != nil || msg.client == nil {
+		return m, nil
+	}
+	m.control = msg.client
+	m.controlSession = msg.session
+	// output events refresh the preview from here on; the preview tick
+	// stops at its next run
+	m.paneSession = ""
+	return m, tea.Batch(waitForControlEvent(m.control), m.previewSelectedCmd())
+}
+
+func (m model) handleControlEvent(msg controlEventMsg) (tea.Model, tea.Cmd) {
+	if !msg.ok || msg.event.Name == tmux.EventExit {
+		// Fall back to polling; the next refresh tick reconnects.
+		m.control.Close()
+		m.control = nil
+		m.controlSession = ""
+		return m, tea.Batch(m.scheduleReload(), m.schedulePreviewTick())
+	}
+	wait := waitForControlEvent(m.control)
+	switch msg.event.Name {
+	case tmux.EventOutput:
+		if m.nav.State == stateGridView {
+			return m, tea.Batch(wait, m.loadGridContentCmd())
+		}
+		if m.controlSession != "" {
+			return m, tea.Batch(wait, loadPaneContentCmd(m.deps.Tmux, m.controlSession, 50))
+		}
+	case tmux.EventSessionChanged:
+		if len(msg.event.Args) == 2 {
+			m.controlSession = msg.event.Args[1]
+		}
+	case tmux.EventSessionsChanged, tmux.EventSessionRenamed, tmux.EventSessionWindowChanged,
+		tmux.EventWindowAdd, tmux.EventWindowClose, tmux.EventWindowRenamed,
+		tmux.EventUnlinkedWindowAdd, tmux.EventUnlinkedWindowClose, tmux.EventLayoutChange:
+		return m, tea.Batch(wait, m.scheduleReload())
+	}
+	return m, wait
+}
+
+// scheduleReload coalesces a burst of events into one reload.
+func (m *model) scheduleReload() tea.Cmd {
+	if m.reloadScheduled {
+		return nil
+	}
+	m.reloadScheduled = true
+	return tea.Tick(controlReloadDelay, func(time.Time) tea.Msg {
+		return controlReloadMsg{}
+	})
+}
+
+func (m *model) reloadCmd() tea.Cmd {
+	if m.nav.GlobalMode {
+		m.refreshInFlight++
+		return loadGlobalDataCmd(m.deps.Cfg, m.deps.Tmux)
+	}
+	if m.deps.Svc == nil {
+		return nil
+	}
+	m.refreshInFlight++
+	return m.loadDataCmd()
+}
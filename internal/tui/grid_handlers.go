@@ -81,7 +81,7 @@ func handleGridEnter(m *model) (tea.Model, tea.Cmd) {
 				if sessionName == "" {
 					sessionName = filepath.Base(panel.Name)
 				}
-				m.jumpTarget = &JumpTarget{SessionName: sessionName, Path: panel.Path, Create: true}
+				m.jumpTarget = &JumpTarget{SessionName: sessionName, Path: panel.Path, RepoRoot: panel.RepoRoot, Create: true}
 			}
 			return *m, tea.Quit
 		case 1:
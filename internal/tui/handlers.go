@@ -5,9 +5,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/nicobailon/treemux/internal/git"
-	"github.com/nicobailon/treemux/internal/shell"
+	"github.com/nicobailon/treemux/internal/tui/theme"
 	"github.com/nicobailon/treemux/internal/workspace"
 )
 
@@ -20,12 +21,7 @@ func handleSelectRepo(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			idx := m.menu.Index()
 			if idx >= 0 && idx < len(m.data.AvailableRepos) {
 				repo := m.data.AvailableRepos[idx]
-				var cmd shell.Commander = m.deps.Tmux.Cmd
-				if cmd == nil {
-					cmd = &shell.ExecCommander{}
-				}
-				g := &git.Git{RepoRoot: repo.Root, Cmd: cmd}
-				m.pending.CreateSvc = workspace.NewService(g, m.deps.Tmux, m.deps.Cfg, cmd)
+				m.pending.CreateSvc = m.serviceFor(repo.Root)
 				m.nav.State = stateCreateName
 				m.input.SetValue("")
 				return *m, m.input.Focus()
@@ -85,9 +81,15 @@ func handleCreateBranch(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.nav.State = stateMain
 					return *m, nil
 				}
-				m.pending.SelectAfter = filepath.Base(svc.WorktreePath(name))
+				opts := workspace.CreateOptions{Base: branch}
+				if remote, ok := sel.Data.(workspace.CreateOptions); ok {
+					opts = remote
+				}
+				if path, err := svc.WorktreePath(name); err == nil {
+					m.pending.SelectAfter = filepath.Base(path)
+				}
 				m.nav.State = stateMain
-				return *m, createWorktreeCmd(svc, name, branch)
+				return *m, createWorktreeCmd(svc, name, opts)
 			}
 		case "esc":
 			m.nav.State = stateMain
@@ -110,7 +112,9 @@ func handleOrphanBranch(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			if sel, ok := m.menu.SelectedItem().(listItem); ok {
 				branch := sel.ItemTitle
 				name := m.pending.Name
-				m.pending.SelectAfter = filepath.Base(m.deps.Svc.WorktreePath(name))
+				if path, err := m.deps.Svc.WorktreePath(name); err == nil {
+					m.pending.SelectAfter = filepath.Base(path)
+				}
 				m.nav.State = stateMain
 				return *m, adoptCmd(m.deps.Svc, name, branch)
 			}
@@ -135,41 +139,25 @@ func handleActionMenu(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch {
 			case strings.Contains(title, "Jump"):
 				if m.pending.Worktree != nil && m.deps.Svc != nil {
-					sessionName := m.deps.Svc.SessionName(m.pending.Worktree.Worktree.Path)
-					if !m.deps.Svc.Tmux.HasSession(sessionName) {
-						_ = m.deps.Svc.Tmux.NewSession(sessionName, m.pending.Worktree.Worktree.Path)
-					}
-					if m.deps.RecentStore != nil && m.deps.Svc.Git != nil {
-						m.deps.RecentStore.Add(m.deps.Svc.Git.RepoRoot, m.pending.Worktree.Worktree.Name, sessionName, m.pending.Worktree.Worktree.Path)
-						_ = m.deps.RecentStore.Save()
-					}
-					m.jumpTarget = &JumpTarget{SessionName: sessionName, Path: m.pending.Worktree.Worktree.Path}
-					return *m, tea.Quit
+					return *m, m.jumpToWorktree(m.deps.Svc, m.pending.Worktree.Worktree.Name, m.pending.Worktree.Worktree.Path)
 				}
 				if m.pending.Global != nil {
-					sessionName := m.pending.Global.Worktree.Name
-					if !m.deps.Tmux.HasSession(sessionName) {
-						_ = m.deps.Tmux.NewSession(sessionName, m.pending.Global.Worktree.Path)
-					}
-					if m.deps.RecentStore != nil {
-						m.deps.RecentStore.Add(m.pending.Global.RepoRoot, m.pending.Global.Worktree.Name, sessionName, m.pending.Global.Worktree.Path)
-						_ = m.deps.RecentStore.Save()
-					}
-					m.jumpTarget = &JumpTarget{SessionName: sessionName, Path: m.pending.Global.Worktree.Path}
-					return *m, tea.Quit
+					return *m, m.jumpToWorktree(m.serviceFor(m.pending.Global.RepoRoot), m.pending.Global.Worktree.Name, m.pending.Global.Worktree.Path)
 				}
 				if m.pending.Name != "" {
 					m.jumpTarget = &JumpTarget{SessionName: m.pending.Name}
 					return *m, tea.Quit
 				}
 			case strings.Contains(title, "Delete worktree"):
-				if m.pending.Worktree != nil && m.deps.Svc != nil && m.deps.Svc.Git != nil {
-					if m.pending.Worktree.Worktree.Path == m.deps.Svc.Git.RepoRoot {
-						m.toast = &toast{message: "Cannot delete current worktree", kind: toastError, expiresAt: time.Now().Add(toastDuration)}
-						m.nav.State = stateMain
-						return *m, toastExpireCmd()
-					}
-					return *m, deleteWorktreeCmd(m.deps.Svc, m.pending.Worktree.Worktree.Path)
+				if m.pending.Worktree != nil {
+					return *m, m.confirmDelete(*m.pending.Worktree)
+				}
+			case strings.Contains(title, "Rename"):
+				if m.pending.Worktree != nil && m.deps.Svc != nil {
+					return *m, m.startRename(m.deps.Svc, m.pending.Worktree.Worktree, false)
+				}
+				if m.pending.Global != nil {
+					return *m, m.startRename(m.serviceFor(m.pending.Global.RepoRoot), m.pending.Global.Worktree, true)
 				}
 			case strings.Contains(title, "Kill session"):
 				if m.pending.Worktree != nil && m.deps.Svc != nil {
@@ -188,6 +176,25 @@ func handleActionMenu(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.nav.NextBranchState = stateOrphanBranch
 					return *m, branchesCmd(m.deps.Svc)
 				}
+			case strings.Contains(title, "Unlock"):
+				if svc, wt, ok := m.pendingWorktree(); ok {
+					m.nav.State = stateMain
+					return *m, unlockWorktreeCmd(svc, wt.Path)
+				}
+			case strings.Contains(title, "Lock"):
+				if svc, wt, ok := m.pendingWorktree(); ok {
+					return *m, m.startLock(svc, wt)
+				}
+			case strings.Contains(title, "Repair"):
+				if svc, wt, ok := m.pendingWorktree(); ok {
+					m.nav.State = stateMain
+					return *m, repairWorktreeCmd(svc, wt.Path)
+				}
+			case strings.Contains(title, "Prune stale"):
+				if svc, _, ok := m.pendingWorktree(); ok {
+					m.nav.State = stateMain
+					return *m, pruneStaleCmd(svc)
+				}
 			}
 			if m.nav.PrevState != 0 {
 				m.nav.State = m.nav.PrevState
@@ -207,6 +214,263 @@ func handleActionMenu(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	return *m, cmd
 }
 
+// pendingWorktree is the worktree the action menu was opened on and the
+// service of its repo.
+func (m *model) pendingWorktree() (*workspace.Service, git.Worktree, bool) {
+	if m.pending.Worktree != nil && m.deps.Svc != nil {
+		return m.deps.Svc, m.pending.Worktree.Worktree, true
+	}
+	if m.pending.Global != nil {
+		return m.serviceFor(m.pending.Global.RepoRoot), m.pending.Global.Worktree, true
+	}
+	return nil, git.Worktree{}, false
+}
+
+// startLock asks for the reason to record when locking wt.
+func (m *model) startLock(svc *workspace.Service, wt git.Worktree) tea.Cmd {
+	m.pending.Lock = &lockTarget{svc: svc, path: wt.Path, name: wt.Name}
+	m.input.SetValue("")
+	m.input.Placeholder = "reason"
+	m.nav.State = stateLockReason
+	return m.input.Focus()
+}
+
+func handleLockReason(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			l := m.pending.Lock
+			m.nav.State = stateMain
+			m.input.Placeholder = "worktree-name"
+			if l == nil {
+				return *m, nil
+			}
+			return *m, lockWorktreeCmd(l.svc, l.path, strings.TrimSpace(m.input.Value()))
+		case "esc":
+			m.nav.State = stateMain
+			m.input.Placeholder = "worktree-name"
+			m.pending.Lock = nil
+			return *m, nil
+		}
+	}
+	return *m, cmd
+}
+
+// startRename asks for the new name of wt, prefilled with its branch.
+func (m *model) startRename(svc *workspace.Service, wt git.Worktree, global bool) tea.Cmd {
+	if svc == nil || svc.Git == nil {
+		return nil
+	}
+	if m.deps.Svc != nil && m.deps.Svc.Git != nil && wt.Path == m.deps.Svc.Git.RepoRoot {
+		m.toast = &toast{message: "Cannot rename current worktree", kind: toastError, expiresAt: time.Now().Add(toastDuration)}
+		m.nav.State = stateMain
+		return toastExpireCmd()
+	}
+	m.pending.Rename = &renameTarget{svc: svc, path: wt.Path, name: wt.Name, branch: wt.Branch, global: global}
+	value := wt.Branch
+	if value == "" {
+		value = wt.Name
+	}
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+	m.nav.State = stateRenameName
+	return m.input.Focus()
+}
+
+func handleRenameName(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			name := strings.TrimSpace(m.input.Value())
+			r := m.pending.Rename
+			if name == "" || r == nil {
+				return *m, nil
+			}
+			r.newName = name
+			if r.branch != "" && r.branch != name {
+				m.menu.SetItems(renameBranchMenuItems(r.branch, name))
+				m.menu.Select(0)
+				m.nav.State = stateRenameBranch
+				return *m, nil
+			}
+			return *m, m.rename(workspace.RenameOptions{KeepBranch: true})
+		case "esc":
+			m.nav.State = stateMain
+			m.pending.Rename = nil
+			return *m, nil
+		}
+	}
+	return *m, cmd
+}
+
+func handleRenameBranch(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.menu, cmd = m.menu.Update(msg)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			switch m.menu.Index() {
+			case 0:
+				return *m, m.rename(workspace.RenameOptions{})
+			case 1:
+				return *m, m.rename(workspace.RenameOptions{KeepBranch: true})
+			}
+			m.nav.State = stateMain
+			m.pending.Rename = nil
+			return *m, nil
+		case "esc":
+			m.nav.State = stateMain
+			m.pending.Rename = nil
+			return *m, nil
+		}
+	}
+	return *m, cmd
+}
+
+// rename runs the pending rename and selects the worktree once reloaded.
+func (m *model) rename(opts workspace.RenameOptions) tea.Cmd {
+	r := m.pending.Rename
+	m.nav.State = stateMain
+	if r == nil {
+		return nil
+	}
+	if path, err := r.svc.WorktreePath(r.newName); err == nil {
+		m.pending.SelectAfter = filepath.Base(path)
+		if r.global {
+			m.pending.SelectAfter = git.RepoName(r.svc.Git.RepoRoot) + "/" + filepath.Base(path)
+		}
+	}
+	return renameCmd(r.svc, r.path, r.newName, opts)
+}
+
+// confirmDelete opens the delete confirmation for wt, showing whether its
+// branch is merged so the user can pick what to do with it.
+func (m *model) confirmDelete(wt workspace.WorktreeState) tea.Cmd {
+	if m.deps.Svc == nil || m.deps.Svc.Git == nil {
+		return nil
+	}
+	if wt.Worktree.Path == m.deps.Svc.Git.RepoRoot {
+		m.toast = &toast{message: "Cannot delete current worktree", kind: toastError, expiresAt: time.Now().Add(toastDuration)}
+		m.nav.State = stateMain
+		return toastExpireCmd()
+	}
+	m.pending.Worktree = &wt
+	m.pending.Branch = m.deps.Svc.BranchInfo(wt.Worktree.Path)
+	m.menu.SetItems(deleteMenuItems(m.pending.Branch))
+	m.menu.Select(0)
+	m.nav.State = stateConfirmDelete
+	return nil
+}
+
+func handleConfirmDelete(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.menu, cmd = m.menu.Update(msg)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			item, ok := m.menu.SelectedItem().(listItem)
+			m.nav.State = stateMain
+			if !ok || m.pending.Worktree == nil || m.deps.Svc == nil {
+				return *m, nil
+			}
+			title := item.ItemTitle
+			opts := workspace.DeleteOptions{Force: true}
+			switch {
+			case strings.Contains(title, "Cancel"):
+				return *m, nil
+			case strings.Contains(title, "keep branch"):
+			case strings.Contains(title, "force delete branch"):
+				opts.Branch = workspace.ForceDeleteBranch
+			case strings.Contains(title, "branch"):
+				opts.Branch = workspace.DeleteMergedBranch
+			}
+			opts.DeleteRemote = strings.Contains(title, "remote")
+			return *m, deleteWorktreeCmd(m.deps.Svc, m.pending.Worktree.Worktree.Path, opts)
+		case "esc":
+			m.nav.State = stateMain
+			return *m, nil
+		}
+	}
+	return *m, cmd
+}
+
+func handlePruneSelect(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.menu, cmd = m.menu.Update(msg)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case " ", "x":
+			idx := m.menu.Index()
+			if idx >= 0 && idx < len(m.pending.Prune) {
+				m.pending.Prune[idx].selected = !m.pending.Prune[idx].selected
+				m.menu.SetItems(pruneMenuItems(m.pending.Prune))
+				m.menu.Select(idx)
+			}
+		case "enter":
+			for _, e := range m.pending.Prune {
+				if e.selected {
+					m.menu.SetItems([]list.Item{
+						listItem{ItemTitle: theme.IconDelete + "  Prune", ItemDesc: "Remove the selected worktrees", Kind: kindHeader},
+						listItem{ItemTitle: "   Back", ItemDesc: "Change the selection", Kind: kindHeader},
+					})
+					m.menu.Select(0)
+					m.nav.State = statePruneConfirm
+					return *m, nil
+				}
+			}
+		case "esc":
+			m.nav.State = stateMain
+			m.pending.Prune = nil
+			return *m, nil
+		}
+	}
+	return *m, cmd
+}
+
+func handlePruneConfirm(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.menu, cmd = m.menu.Update(msg)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			item, ok := m.menu.SelectedItem().(listItem)
+			if ok && strings.Contains(item.ItemTitle, "Prune") && m.deps.Svc != nil {
+				var selected []workspace.PruneCandidate
+				for _, e := range m.pending.Prune {
+					if e.selected {
+						selected = append(selected, e.candidate)
+					}
+				}
+				m.pending.Prune = nil
+				m.nav.State = stateMain
+				return *m, pruneWorktreesCmd(m.deps.Svc, selected)
+			}
+			m.menu.SetItems(pruneMenuItems(m.pending.Prune))
+			m.menu.Select(0)
+			m.nav.State = statePruneSelect
+			return *m, nil
+		case "esc":
+			m.menu.SetItems(pruneMenuItems(m.pending.Prune))
+			m.menu.Select(0)
+			m.nav.State = statePruneSelect
+			return *m, nil
+		}
+	}
+	return *m, cmd
+}
+
+// jumpToWorktree prepares the worktree's session in the background, which
+// runs on_jump hooks; the resulting jumpMsg records it as recent and quits
+// so the caller can switch to it.
+func (m *model) jumpToWorktree(svc *workspace.Service, name, path string) tea.Cmd {
+	m.nav.State = stateMain
+	return jumpCmd(svc, name, path)
+}
+
 func handleCommandPalette(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	m.commandPalette, cmd = m.commandPalette.Update(msg)
@@ -228,3 +492,11 @@ func handleCommandPalette(m *model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 	return *m, cmd
 }
+
+// errorMessage is err with a suggestion for the causes treemux can name.
+func errorMessage(err error) string {
+	if hint := workspace.Hint(err); hint != "" {
+		return err.Error() + " (" + hint + ")"
+	}
+	return err.Error()
+}
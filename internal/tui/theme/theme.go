@@ -37,6 +37,9 @@ const (
 	IconDelete   = ""
 	IconKill     = ""
 	IconAdopt    = ""
+	IconRename   = ""
+	IconLock     = ""
+	IconRepair   = ""
 )
 
 var (
@@ -0,0 +1,111 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/tui/theme"
+)
+
+// BranchLabel is the branch checked out in wt, or the commit its detached
+// HEAD points at.
+func BranchLabel(wt git.Worktree) string {
+	if !wt.Detached {
+		return wt.Branch
+	}
+	if len(wt.Head) >= 7 {
+		return "detached @ " + wt.Head[:7]
+	}
+	return "detached"
+}
+
+// WorktreeBadges renders the locked and prunable markers of a worktree, or
+// "" when it has neither.
+func WorktreeBadges(locked, prunable bool) string {
+	var badges []string
+	if locked {
+		badges = append(badges, theme.WarnStyle.Render("locked"))
+	}
+	if prunable {
+		badges = append(badges, theme.ErrorStyle.Render("prunable"))
+	}
+	return strings.Join(badges, " ")
+}
+
+// StatusBadge is the short status of a worktree for list rows and grid
+// panels: the operation in progress, then conflicted, modified and staged
+// counts, or the untracked count when those are all zero.
+func StatusBadge(s *git.StatusSummary) string {
+	if s == nil {
+		return theme.SuccessStyle.Render(theme.IconClean)
+	}
+	var parts []string
+	if s.Operation != "" {
+		parts = append(parts, theme.ErrorStyle.Render(s.Operation))
+	}
+	var counts []string
+	if s.Conflicted > 0 {
+		counts = append(counts, theme.ErrorStyle.Render(fmt.Sprintf("%dC", s.Conflicted)))
+	}
+	if s.Modified > 0 {
+		counts = append(counts, theme.WarnStyle.Render(fmt.Sprintf("%dM", s.Modified)))
+	}
+	if s.Staged > 0 {
+		counts = append(counts, theme.SectionStyle.Render(fmt.Sprintf("%dS", s.Staged)))
+	}
+	switch {
+	case len(counts) > 0:
+		parts = append(parts, counts...)
+	case s.Untracked > 0:
+		parts = append(parts, theme.DimStyle.Render(fmt.Sprintf("%d?", s.Untracked)))
+	case s.Clean:
+		parts = append(parts, theme.SuccessStyle.Render(theme.IconClean))
+	}
+	return strings.Join(parts, " ")
+}
+
+// StatusCounts spells out the changes in a worktree, e.g. "2 modified",
+// "1 conflicted". It is empty for a clean worktree.
+func StatusCounts(s *git.StatusSummary) []string {
+	var parts []string
+	if s.Conflicted > 0 {
+		parts = append(parts, theme.ErrorStyle.Render(fmt.Sprintf("%d conflicted", s.Conflicted)))
+	}
+	if s.Modified > 0 {
+		parts = append(parts, theme.WarnStyle.Render(fmt.Sprintf("%d modified", s.Modified)))
+	}
+	if s.Staged > 0 {
+		staged := fmt.Sprintf("%d staged", s.Staged)
+		if s.Renamed > 0 {
+			staged += fmt.Sprintf(" (%d renamed)", s.Renamed)
+		}
+		parts = append(parts, theme.SectionStyle.Render(staged))
+	}
+	if s.Untracked > 0 {
+		parts = append(parts, theme.DimStyle.Render(fmt.Sprintf("%d untracked", s.Untracked)))
+	}
+	return parts
+}
+
+// SyncLabel describes the upstream of a worktree and how far it is ahead
+// and behind, or "" without an upstream.
+func SyncLabel(s *git.StatusSummary) string {
+	if s.Upstream == "" {
+		return ""
+	}
+	if s.UpstreamGone {
+		return theme.WarnStyle.Render(s.Upstream + " (gone)")
+	}
+	label := theme.SubTextStyle.Render(s.Upstream)
+	if s.Ahead > 0 {
+		label += " " + theme.SuccessStyle.Render(fmt.Sprintf("%d ahead", s.Ahead))
+	}
+	if s.Behind > 0 {
+		label += " " + theme.WarnStyle.Render(fmt.Sprintf("%d behind", s.Behind))
+	}
+	if s.Ahead == 0 && s.Behind == 0 {
+		label += " " + theme.DimStyle.Render("up to date")
+	}
+	return label
+}
@@ -57,3 +57,11 @@ func RenderPrompt(title, label, input string) string {
 	labelStyled := theme.SectionStyle.Render(label)
 	return theme.ModalStyle.Render(header + "\n" + divider + "\n\n" + labelStyled + "\n" + input)
 }
+
+// RenderConfirm renders a menu with a status line between the title and the
+// choices.
+func RenderConfirm(title, detail string, m *list.Model) string {
+	header := theme.TitleStyle.Render("▲ " + title)
+	divider := theme.SeparatorStyle.Render("────────────────────────")
+	return theme.ModalStyle.Render(header + "\n" + divider + "\n" + detail + "\n\n" + m.View())
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/nicobailon/treemux/internal/git"
 	"github.com/nicobailon/treemux/internal/tui/theme"
 )
 
@@ -12,13 +13,15 @@ type GridPanel struct {
 	Name        string
 	SessionName string
 	Path        string
+	RepoRoot    string
 	Branch      string
+	Locked      bool
+	Prunable    bool
 	Content     string
 	HasSession  bool
 	IsOrphan    bool
 	IsRecent    bool
-	Modified    int
-	Staged      int
+	Status      *git.StatusSummary
 	Windows     int
 	Panes       int
 	Processes   []string
@@ -300,11 +303,21 @@ func (g *GridState) RenderView(width, height int) string {
 			line1 = theme.CachedInactiveStyle.Render("* orphaned")
 		} else if panel.Branch != "" {
 			branchDisplay := panel.Branch
+			badges := WorktreeBadges(panel.Locked, panel.Prunable)
 			maxBranchLen := innerWidth - 4
+			if badges != "" {
+				maxBranchLen -= lipgloss.Width(badges) + 1
+			}
+			if maxBranchLen < 5 {
+				maxBranchLen = 5
+			}
 			if len(branchDisplay) > maxBranchLen {
 				branchDisplay = branchDisplay[:maxBranchLen-1] + "…"
 			}
 			line1 = theme.CachedBranchStyle.Render("⎇ " + branchDisplay)
+			if badges != "" {
+				line1 += " " + badges
+			}
 		}
 
 		var line2 string
@@ -322,6 +335,12 @@ func (g *GridState) RenderView(width, height int) string {
 		if globalIdx < 9 {
 			line3 = theme.CachedInactiveStyle.Render(fmt.Sprintf("[%d]", globalIdx+1))
 		}
+		if panel.Status != nil && !panel.Status.Clean {
+			if line3 != "" {
+				line3 += " "
+			}
+			line3 += StatusBadge(panel.Status)
+		}
 
 		content := lipgloss.NewStyle().
 			Width(innerWidth).
@@ -477,6 +496,9 @@ func (g *GridState) RenderDetail(width, height int) string {
 	if panel.Branch != "" {
 		infoLines = append(infoLines, lipgloss.NewStyle().Foreground(theme.Accent2).Render("⎇ "+panel.Branch))
 	}
+	if badges := WorktreeBadges(panel.Locked, panel.Prunable); badges != "" {
+		infoLines = append(infoLines, badges)
+	}
 	if panel.Path != "" {
 		pathDisplay := panel.Path
 		maxPath := modalWidth - 8
@@ -496,15 +518,19 @@ func (g *GridState) RenderDetail(width, height int) string {
 		infoLines = append(infoLines, lipgloss.NewStyle().Foreground(theme.DimColor).Render("○ no active session"))
 	}
 
-	if panel.Modified > 0 || panel.Staged > 0 {
-		var parts []string
-		if panel.Modified > 0 {
-			parts = append(parts, lipgloss.NewStyle().Foreground(theme.WarnColor).Render(fmt.Sprintf("%d modified", panel.Modified)))
+	if panel.Status != nil {
+		if parts := StatusCounts(panel.Status); len(parts) > 0 {
+			infoLines = append(infoLines, strings.Join(parts, "  "))
+		}
+		if sync := SyncLabel(panel.Status); sync != "" {
+			infoLines = append(infoLines, "⇅ "+sync)
+		}
+		if panel.Status.Stashes > 0 {
+			infoLines = append(infoLines, lipgloss.NewStyle().Foreground(theme.DimColor).Render(fmt.Sprintf("%d stashed", panel.Status.Stashes)))
 		}
-		if panel.Staged > 0 {
-			parts = append(parts, lipgloss.NewStyle().Foreground(theme.SuccessColor).Render(fmt.Sprintf("%d staged", panel.Staged)))
+		if panel.Status.Operation != "" {
+			infoLines = append(infoLines, theme.ErrorStyle.Render(panel.Status.Operation+" in progress"))
 		}
-		infoLines = append(infoLines, strings.Join(parts, "  "))
 	}
 
 	infoSection := lipgloss.NewStyle().
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nicobailon/treemux/internal/tui/builders"
+	"github.com/nicobailon/treemux/internal/tui/views"
+	"github.com/nicobailon/treemux/internal/workspace"
+)
+
+// watchWorktreesCmd keeps the filesystem watcher in sync with the loaded
+// worktrees, creating it on the first load.
+func (m *model) watchWorktreesCmd() tea.Cmd {
+	if m.nav.GlobalMode || m.deps.Svc == nil {
+		return nil
+	}
+	var wait tea.Cmd
+	if m.watcher == nil {
+		w, err := m.deps.Svc.NewWatcher()
+		if err != nil {
+			return nil
+		}
+		m.watcher = w
+		wait = waitForWorktreeChange(w)
+	}
+	paths := make([]string, len(m.data.States))
+	for i, st := range m.data.States {
+		paths[i] = st.Worktree.Path
+	}
+	w := m.watcher
+	return tea.Batch(wait, func() tea.Msg {
+		w.Set(paths)
+		return nil
+	})
+}
+
+func waitForWorktreeChange(w *workspace.Watcher) tea.Cmd {
+	return func() tea.Msg {
+		path, ok := <-w.Changes()
+		if !ok {
+			return nil
+		}
+		return worktreeChangedMsg{path: path}
+	}
+}
+
+func refreshWorktreeCmd(svc *workspace.Service, path string) tea.Cmd {
+	return func() tea.Msg {
+		st, err := svc.Refresh(path)
+		return worktreeStateMsg{state: st, err: err}
+	}
+}
+
+// handleWorktreeState replaces one worktree's state in the list, preview and
+// grid without reloading the others.
+func (m model) handleWorktreeState(msg worktreeStateMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		return m, nil
+	}
+	path := msg.state.Worktree.Path
+	found := false
+	for i := range m.data.States {
+		if m.data.States[i].Worktree.Path == path {
+			m.data.States[i] = msg.state
+			found = true
+		}
+	}
+	if !found {
+		return m, nil
+	}
+	repoRoot := ""
+	if m.deps.Svc != nil && m.deps.Svc.Git != nil {
+		repoRoot = m.deps.Svc.Git.RepoRoot
+	}
+	m.list.SetItems(builders.BuildItems(m.data.States, m.data.Orphans, m.data.RecentEntries, repoRoot))
+
+	for _, panels := range [][]views.GridPanel{m.grid.Panels, m.grid.Available} {
+		for i := range panels {
+			if panels[i].Path != path {
+				continue
+			}
+			panels[i].Branch = views.BranchLabel(msg.state.Worktree)
+			panels[i].Locked = msg.state.Worktree.Locked
+			panels[i].Prunable = msg.state.Worktree.Prunable
+			if msg.state.Status != nil {
+				panels[i].Status = msg.state.Status
+			}
+		}
+	}
+	m.grid.InvalidateFilterCache()
+	return m, nil
+}
@@ -0,0 +1,204 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+// maxCollectors bounds the number of worktrees inspected at once.
+var maxCollectors = min(runtime.NumCPU(), 8)
+
+// cacheTTL bounds how long git state is reused while the files in
+// stateFiles are unchanged; editing a file does not touch them.
+const cacheTTL = 15 * time.Second
+
+type cachedGitState struct {
+	// files are the paths from stateFiles and mtimes their modification
+	// times when the state was read.
+	files     []string
+	mtimes    []time.Time
+	fetchedAt time.Time
+	status    *git.StatusSummary
+	ahead     int
+	behind    int
+	commits   []git.Commit
+}
+
+// collect builds the state of every worktree in parallel. Session data comes
+// from snap and the process table is read once for all sessions.
+func (s *Service) collect(worktrees []git.Worktree, snap *tmux.Snapshot) []WorktreeState {
+	var procs *tmux.ProcessTree
+	if len(snap.Names) > 0 {
+		procs, _ = s.Tmux.ProcessTree()
+	}
+
+	states := make([]WorktreeState, len(worktrees))
+	sem := make(chan struct{}, max(maxCollectors, 1))
+	var wg sync.WaitGroup
+	for i, wt := range worktrees {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			sessionName, hasSession := s.MatchSession(snap, wt)
+			gs := s.gitState(wt.Path)
+			st := WorktreeState{
+				Worktree:    wt,
+				SessionName: sessionName,
+				HasSession:  hasSession,
+				Status:      gs.status,
+				Ahead:       gs.ahead,
+				Behind:      gs.behind,
+				Commits:     gs.commits,
+			}
+			if st.HasSession {
+				st.SessionInfo = snap.Sessions[sessionName]
+				if procs != nil {
+					st.Processes = procs.Names(snap.PanePIDs[sessionName])
+				}
+			}
+			states[i] = st
+		}()
+	}
+	wg.Wait()
+	return states
+}
+
+// gitState returns the status, ahead/behind counts and recent commits of the
+// worktree at path, reusing the cached result while its index, HEAD and
+// upstream ref are unchanged.
+func (s *Service) gitState(path string) cachedGitState {
+	s.cacheMu.Lock()
+	cached, ok := s.cache[path]
+	s.cacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < cacheTTL && unchanged(cached.files, cached.mtimes) {
+		return cached
+	}
+
+	// Stat before reading so that a change made meanwhile is seen next time.
+	files := stateFiles(path, nil)
+	gs := cachedGitState{mtimes: modTimes(files), fetchedAt: time.Now()}
+	gs.status, _ = s.Git.Status(path)
+	if gs.status != nil {
+		gs.ahead, gs.behind = gs.status.Ahead, gs.status.Behind
+	}
+	gs.commits, _ = s.Git.Log(path, 6)
+	gs.files = stateFiles(path, gs.status)
+	gs.mtimes = append(gs.mtimes, modTimes(gs.files[len(files):])...)
+
+	s.cacheMu.Lock()
+	if s.cache == nil {
+		s.cache = map[string]cachedGitState{}
+	}
+	s.cache[path] = gs
+	s.cacheMu.Unlock()
+	return gs
+}
+
+// stateFiles lists the files git rewrites when the state of the worktree at
+// path changes: its index, HEAD and HEAD reflog, which moves on every
+// commit, checkout and reset, the repository config, which names the
+// upstream, and with a status also the upstream ref.
+func stateFiles(path string, status *git.StatusSummary) []string {
+	gitDir := git.GitDir(path)
+	common := git.CommonDir(path)
+	files := []string{
+		filepath.Join(gitDir, "index"),
+		filepath.Join(gitDir, "HEAD"),
+		filepath.Join(gitDir, "logs", "HEAD"),
+		filepath.Join(common, "config"),
+	}
+	if status != nil && status.Upstream != "" {
+		files = append(files,
+			filepath.Join(common, "refs", "remotes", filepath.FromSlash(status.Upstream)),
+			filepath.Join(common, "packed-refs"))
+	}
+	return files
+}
+
+func modTimes(files []string) []time.Time {
+	mtimes := make([]time.Time, len(files))
+	for i, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			mtimes[i] = fi.ModTime()
+		}
+	}
+	return mtimes
+}
+
+// unchanged reports whether files still have mtimes. A missing index means
+// git state cannot be tracked, so it always counts as changed.
+func unchanged(files []string, mtimes []time.Time) bool {
+	current := modTimes(files)
+	if len(current) == 0 || current[0].IsZero() {
+		return false
+	}
+	for i := range current {
+		if !current[i].Equal(mtimes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Statuses returns the git status of the worktrees at paths, read in
+// parallel and through the same cache as List. Worktrees whose status
+// cannot be read are left out.
+func (s *Service) Statuses(paths []string) map[string]*git.StatusSummary {
+	statuses := make(map[string]*git.StatusSummary, len(paths))
+	var mu sync.Mutex
+	sem := make(chan struct{}, max(maxCollectors, 1))
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if gs := s.gitState(path); gs.status != nil {
+				mu.Lock()
+				statuses[path] = gs.status
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// Invalidate drops cached git state for path, or for every worktree when
+// path is empty.
+func (s *Service) Invalidate(path string) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if path == "" {
+		s.cache = nil
+		return
+	}
+	delete(s.cache, path)
+}
+
+// Refresh recomputes the state of the worktree at path, bypassing the cache.
+func (s *Service) Refresh(path string) (WorktreeState, error) {
+	s.Invalidate(path)
+	worktrees, err := s.Git.WorktreeList()
+	if err != nil {
+		return WorktreeState{}, err
+	}
+	for _, wt := range worktrees {
+		if wt.Path == path {
+			return s.collect([]git.Worktree{wt}, s.Tmux.Snapshot())[0], nil
+		}
+	}
+	return WorktreeState{}, fmt.Errorf("worktree %s not found", path)
+}
@@ -0,0 +1,32 @@
+package workspace
+
+import (
+	"errors"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+// Hint suggests what to do about a failed git or tmux command, or returns
+// "" when err has no known cause. The CLI and the TUI both show it.
+func Hint(err error) string {
+	switch {
+	case errors.Is(err, git.ErrBranchExists):
+		return "check the existing branch out instead, or pick another name"
+	case errors.Is(err, git.ErrBranchCheckedOut):
+		return "the branch already has a worktree; jump to it instead"
+	case errors.Is(err, git.ErrPathExists):
+		return "move the directory out of the way or change path_pattern"
+	case errors.Is(err, git.ErrDirtyWorktree):
+		return "commit or stash the changes first, or force the delete"
+	case errors.Is(err, git.ErrWorktreeLocked):
+		return "unlock the worktree first"
+	case errors.Is(err, tmux.ErrNoServer):
+		return "no tmux server is running; start one with tmux new-session"
+	case errors.Is(err, tmux.ErrSessionNotFound):
+		return "the session has exited; jumping to the worktree starts it again"
+	case errors.Is(err, tmux.ErrSessionExists):
+		return "another session has that name; rename it or change session_name"
+	}
+	return ""
+}
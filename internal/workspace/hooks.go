@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/config"
+	"github.com/nicobailon/treemux/internal/git"
+)
+
+type HookEvent string
+
+const (
+	HookPostCreate HookEvent = "post_create"
+	HookPreDelete  HookEvent = "pre_delete"
+	HookPostDelete HookEvent = "post_delete"
+	HookPostAdopt  HookEvent = "post_adopt"
+	HookOnJump     HookEvent = "on_jump"
+)
+
+// HookError reports the first hook command of an event that failed.
+type HookError struct {
+	Event   HookEvent
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("%s hook %q failed: %v", e.Event, e.Command, e.Err)
+	if last := lastLine(e.Output); last != "" {
+		msg += ": " + last
+	}
+	return msg
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Aborts reports whether the failure stopped the operation that triggered
+// the hook. Only pre-* hooks can abort.
+func (e *HookError) Aborts() bool {
+	return e.Event == HookPreDelete
+}
+
+type hookTarget struct {
+	Path    string
+	Branch  string
+	Session string
+}
+
+func (s *Service) hookCommands(event HookEvent) []string {
+	h := s.Config.Hooks
+	switch event {
+	case HookPostCreate:
+		return h.PostCreate
+	case HookPreDelete:
+		return h.PreDelete
+	case HookPostDelete:
+		return h.PostDelete
+	case HookPostAdopt:
+		return h.PostAdopt
+	case HookOnJump:
+		return h.OnJump
+	}
+	return nil
+}
+
+// runHooks runs the commands configured for event one after another with
+// sh -c, stopping at the first failure. Output is written to the hook log.
+func (s *Service) runHooks(event HookEvent, target hookTarget) error {
+	commands := s.hookCommands(event)
+	if len(commands) == 0 {
+		return nil
+	}
+	repoRoot := s.Git.RepoRoot
+	env := []string{
+		"TREEMUX_EVENT=" + string(event),
+		"TREEMUX_REPO_ROOT=" + repoRoot,
+		"TREEMUX_REPO_NAME=" + git.RepoName(repoRoot),
+		"TREEMUX_WORKTREE_PATH=" + target.Path,
+		"TREEMUX_WORKTREE_NAME=" + filepath.Base(target.Path),
+		"TREEMUX_BRANCH=" + target.Branch,
+		"TREEMUX_SESSION=" + target.Session,
+	}
+	dir := target.Path
+	if event == HookPostDelete {
+		dir = repoRoot
+	}
+
+	log, closeLog := s.hookLog()
+	defer closeLog()
+	for _, command := range commands {
+		out, err := s.Cmd.RunEnv(dir, env, "sh", "-c", command)
+		fmt.Fprintf(log, "[%s] %s %s: %s\n", time.Now().Format(time.RFC3339), event, filepath.Base(target.Path), command)
+		if len(out) > 0 {
+			log.Write(out)
+			if out[len(out)-1] != '\n' {
+				fmt.Fprintln(log)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(log, "  -> %v\n", err)
+			return &HookError{Event: event, Command: command, Output: string(out), Err: err}
+		}
+	}
+	return nil
+}
+
+// hookLog returns Service.HookLog when set, otherwise hooks.log in the
+// treemux config dir.
+func (s *Service) hookLog() (io.Writer, func()) {
+	if s.HookLog != nil {
+		return s.HookLog, func() {}
+	}
+	dir := config.Dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return io.Discard, func() {}
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "hooks.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return io.Discard, func() {}
+	}
+	return f, func() { f.Close() }
+}
+
+func (s *Service) branchAt(path string) string {
+	out, err := s.Cmd.Run("git", "-C", path, "branch", "--show-current")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,81 @@
+package workspace
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StepError reports the step of a multi-step operation that failed and what
+// happened to the steps completed before it.
+type StepError struct {
+	Op   string
+	Step string
+	Err  error
+	// RolledBack lists the completed steps that were undone, newest first.
+	RolledBack []string
+	// UndoErrors holds the steps that could not be undone, as "step: err".
+	UndoErrors []error
+}
+
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("%s: %s: %v", e.Op, e.Step, e.Err)
+	if len(e.RolledBack) > 0 {
+		msg += " (rolled back: " + strings.Join(e.RolledBack, ", ") + ")"
+	}
+	for _, err := range e.UndoErrors {
+		msg += "; could not undo " + err.Error()
+	}
+	return msg
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// journal runs the steps of an operation in order and records how to undo
+// each one. When a step fails, the completed steps are undone newest first.
+type journal struct {
+	op   string
+	done []journalEntry
+}
+
+type journalEntry struct {
+	step string
+	undo func() error
+}
+
+func newJournal(op string) *journal {
+	return &journal{op: op}
+}
+
+// run performs step. undo reverts it if a later step fails; nil means the
+// step has nothing to undo.
+func (j *journal) run(step string, do, undo func() error) error {
+	if err := do(); err != nil {
+		return j.rollback(step, err)
+	}
+	if undo != nil {
+		j.done = append(j.done, journalEntry{step: step, undo: undo})
+	}
+	return nil
+}
+
+// commit marks the point of no return: steps completed so far are kept even
+// if a later step fails.
+func (j *journal) commit() {
+	j.done = nil
+}
+
+func (j *journal) rollback(step string, err error) error {
+	stepErr := &StepError{Op: j.op, Step: step, Err: err}
+	for i := len(j.done) - 1; i >= 0; i-- {
+		e := j.done[i]
+		if undoErr := e.undo(); undoErr != nil {
+			stepErr.UndoErrors = append(stepErr.UndoErrors, fmt.Errorf("%s: %w", e.step, undoErr))
+			continue
+		}
+		stepErr.RolledBack = append(stepErr.RolledBack, e.step)
+	}
+	j.done = nil
+	return stepErr
+}
@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/nicobailon/treemux/internal/config"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+// StartSession creates the tmux session for a worktree, building the
+// configured layout when one is set, and tags it with the worktree.
+func (s *Service) StartSession(name, path string) error {
+	layout := s.Config.Layout
+	if layout == nil || len(layout.Windows) == 0 {
+		if err := s.Tmux.NewSession(name, path); err != nil {
+			return err
+		}
+	} else if err := s.buildLayout(name, path, layout); err != nil {
+		// A session that already existed is not ours to kill.
+		if !errors.Is(err, tmux.ErrSessionExists) && s.Tmux.HasSession(name) {
+			_ = s.Tmux.KillSession(name)
+		}
+		return err
+	}
+	s.tagSession(name, path)
+	return nil
+}
+
+// EnsureSession starts the session unless it is already running.
+func (s *Service) EnsureSession(name, path string) error {
+	if s.Tmux.HasSession(name) {
+		return nil
+	}
+	return s.StartSession(name, path)
+}
+
+func (s *Service) buildLayout(name, root string, layout *config.Layout) error {
+	firstWindow := ""
+	for i, w := range layout.Windows {
+		dir := layoutDir(root, w.Dir)
+		var windowID string
+		var err error
+		if i == 0 {
+			windowID, err = s.Tmux.NewSessionWindow(name, dir, w.Name)
+			firstWindow = windowID
+		} else {
+			windowID, err = s.Tmux.NewWindow(name, w.Name, dir)
+		}
+		if err != nil {
+			return fmt.Errorf("layout window %q: %w", w.Name, err)
+		}
+		if w.Command != "" {
+			if err := s.Tmux.SendKeys(windowID, w.Command); err != nil {
+				return fmt.Errorf("layout window %q: %w", w.Name, err)
+			}
+		}
+		for _, p := range w.Panes {
+			paneID, err := s.Tmux.SplitWindow(windowID, layoutDir(dir, p.Dir), p.Split == "horizontal", p.Size)
+			if err != nil {
+				return fmt.Errorf("layout window %q: split: %w", w.Name, err)
+			}
+			if p.Command != "" {
+				if err := s.Tmux.SendKeys(paneID, p.Command); err != nil {
+					return fmt.Errorf("layout window %q: %w", w.Name, err)
+				}
+			}
+		}
+		if w.Layout != "" {
+			if err := s.Tmux.SelectLayout(windowID, w.Layout); err != nil {
+				return fmt.Errorf("layout window %q: %w", w.Name, err)
+			}
+		}
+	}
+	if firstWindow != "" {
+		return s.Tmux.SelectWindow(firstWindow)
+	}
+	return nil
+}
+
+func layoutDir(base, dir string) string {
+	if dir == "" {
+		return base
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(base, dir)
+}
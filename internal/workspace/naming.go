@@ -0,0 +1,86 @@
+package workspace
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/nicobailon/treemux/internal/git"
+)
+
+// builtinSessionNames are the named session_name values.
+var builtinSessionNames = map[string]string{
+	"folder": "{{.Name}}",
+	"branch": "{{if .Branch}}{{.Branch}}{{else}}{{.Name}}{{end}}",
+}
+
+// SessionVars are the variables available to a session_name template.
+// RepoName and RepoRoot refer to the main worktree, so every worktree of a
+// repository sees the same values; Name is the worktree's folder name.
+type SessionVars struct {
+	RepoName   string
+	RepoRoot   string
+	Name       string
+	Branch     string
+	BranchSlug string
+	User       string
+}
+
+// sessionNameReplacer maps characters tmux does not allow in session names;
+// tmux itself would silently rename "v1.2" to "v1_2".
+var sessionNameReplacer = strings.NewReplacer(".", "_", ":", "_")
+
+// ResolveSessionName expands pattern ("folder", "branch" or a Go template
+// such as "{{.RepoName}}/{{.Branch}}") for vars. An empty or invalid result
+// falls back to the folder name.
+func ResolveSessionName(pattern string, vars SessionVars) string {
+	if builtin, ok := builtinSessionNames[pattern]; ok {
+		pattern = builtin
+	} else if !strings.Contains(pattern, "{{") {
+		pattern = builtinSessionNames["folder"]
+	}
+	name := vars.Name
+	if tmpl, err := template.New("session_name").Option("missingkey=error").Parse(pattern); err == nil {
+		var b strings.Builder
+		if tmpl.Execute(&b, vars) == nil && strings.TrimSpace(b.String()) != "" {
+			name = strings.TrimSpace(b.String())
+		}
+	}
+	return sessionNameReplacer.Replace(name)
+}
+
+// SessionName returns the tmux session name for the worktree at wtPath.
+func (s *Service) SessionName(wtPath string) string {
+	return s.sessionName(wtPath, func() string { return s.branchAt(wtPath) })
+}
+
+// SessionNameFor is SessionName for a worktree whose branch is already known.
+func (s *Service) SessionNameFor(wt git.Worktree) string {
+	return s.sessionName(wt.Path, func() string { return wt.Branch })
+}
+
+func (s *Service) sessionName(wtPath string, branch func() string) string {
+	pattern := s.Config.SessionName
+	vars := SessionVars{Name: filepath.Base(wtPath)}
+	if pattern == "" || pattern == "folder" {
+		return sessionNameReplacer.Replace(vars.Name)
+	}
+	vars.Branch = branch()
+	vars.BranchSlug = Slug(vars.Branch)
+	if pattern != "branch" {
+		vars.RepoRoot = s.mainWorktree()
+		vars.RepoName = git.RepoName(vars.RepoRoot)
+		vars.User = currentUser()
+	}
+	return ResolveSessionName(pattern, vars)
+}
+
+func (s *Service) mainWorktree() string {
+	s.mainOnce.Do(func() {
+		if s.Git == nil {
+			return
+		}
+		s.mainRoot = s.Git.MainWorktree()
+	})
+	return s.mainRoot
+}
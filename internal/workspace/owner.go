@@ -0,0 +1,57 @@
+package workspace
+
+import (
+	"fmt"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+// tagSession records the worktree at path as the owner of session. Failing
+// to tag is not fatal: an untagged session is still matched by name.
+func (s *Service) tagSession(session, path string) {
+	_ = s.Tmux.SetOwner(session, tmux.Owner{Repo: s.mainWorktree(), Worktree: path, Branch: s.branchAt(path)})
+}
+
+// MatchSession finds the session of wt in snap. A session tagged with wt's
+// path wins, even after a rename. Otherwise the session_name is used, unless
+// that session is tagged with another worktree. The returned name is the
+// session_name when no session matched.
+func (s *Service) MatchSession(snap *tmux.Snapshot, wt git.Worktree) (string, bool) {
+	if name, ok := snap.Owned(wt.Path); ok {
+		return name, true
+	}
+	name := s.SessionNameFor(wt)
+	info, ok := snap.Sessions[name]
+	return name, ok && !info.Owner.Managed()
+}
+
+// SessionFor returns the session of the worktree at path and whether it is
+// running. The error reports a session_name taken by another worktree's
+// session.
+func (s *Service) SessionFor(path string) (string, bool, error) {
+	return s.SessionIn(s.Tmux.Snapshot(), path)
+}
+
+// SessionIn is SessionFor against a snapshot the caller already took.
+func (s *Service) SessionIn(snap *tmux.Snapshot, path string) (string, bool, error) {
+	name, ok := s.MatchSession(snap, git.Worktree{Path: path, Branch: s.branchAt(path)})
+	if !ok && snap.Has(name) {
+		return name, false, fmt.Errorf("session %s belongs to worktree %s", name, snap.Sessions[name].Owner.Worktree)
+	}
+	return name, ok, nil
+}
+
+// Orphans returns the sessions treemux created for a worktree of repos that
+// were not claimed by any existing worktree. Sessions without treemux's tags
+// are never orphans.
+func Orphans(snap *tmux.Snapshot, claimed, repos map[string]bool) []string {
+	orphans := []string{}
+	for _, name := range snap.Names {
+		owner := snap.Sessions[name].Owner
+		if owner.Managed() && repos[owner.Repo] && !claimed[name] {
+			orphans = append(orphans, name)
+		}
+	}
+	return orphans
+}
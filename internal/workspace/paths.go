@@ -0,0 +1,148 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/nicobailon/treemux/internal/git"
+)
+
+// builtinPathPatterns are the named path_pattern values.
+var builtinPathPatterns = map[string]string{
+	"sibling":      "{{.RepoRoot}}/../{{.RepoName}}-{{.BranchSlug}}",
+	"subdirectory": "{{.RepoRoot}}/.worktrees/{{.BranchSlug}}",
+}
+
+// bareWorktreePattern replaces either built-in pattern for the
+// project/.bare and project/.git layouts of a bare repository, keeping
+// worktrees next to the git dir as project/feat. A name.git repository uses
+// namedBareWorktreePattern and gets name-feat beside it instead.
+const (
+	bareWorktreePattern      = "{{.RepoRoot}}/../{{.BranchSlug}}"
+	namedBareWorktreePattern = "{{.RepoRoot}}/../{{.RepoName}}-{{.BranchSlug}}"
+)
+
+// PathVars are the variables available to a path_pattern template. Name is
+// the worktree (branch) name as given and may contain slashes; BranchSlug is
+// the same name flattened into a single safe path component.
+type PathVars struct {
+	RepoName   string
+	RepoRoot   string
+	Name       string
+	BranchSlug string
+	User       string
+}
+
+// WorktreePath returns where the worktree for name goes according to
+// path_pattern: "sibling" (default), "subdirectory" or a Go template such as
+// "~/worktrees/{{.RepoName}}/{{.BranchSlug}}". Relative results are taken
+// relative to the repository root, which is the git dir of a bare
+// repository.
+func (s *Service) WorktreePath(name string) (string, error) {
+	slug := Slug(name)
+	if slug == "" {
+		return "", fmt.Errorf("invalid worktree name %q", name)
+	}
+	root := s.Git.RepoRoot
+	bare := git.IsBareDir(s.mainWorktree())
+	if bare {
+		root = s.mainWorktree()
+	}
+	pattern := s.Config.PathPattern
+	if builtin, ok := builtinPathPatterns[pattern]; ok || pattern == "" {
+		if !ok {
+			builtin = builtinPathPatterns["sibling"]
+		}
+		pattern = builtin
+		switch base := filepath.Base(root); {
+		case bare && (base == ".bare" || base == ".git"):
+			pattern = bareWorktreePattern
+		case bare:
+			pattern = namedBareWorktreePattern
+		}
+	} else if !strings.Contains(pattern, "{{") {
+		return "", fmt.Errorf("path_pattern %q is neither sibling, subdirectory nor a template", pattern)
+	}
+	tmpl, err := template.New("path_pattern").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", fmt.Errorf("path_pattern: %w", err)
+	}
+	vars := PathVars{
+		RepoName:   git.RepoName(root),
+		RepoRoot:   root,
+		Name:       name,
+		BranchSlug: slug,
+		User:       currentUser(),
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("path_pattern: %w", err)
+	}
+	path := strings.TrimSpace(b.String())
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if path == filepath.Clean(root) {
+		return "", fmt.Errorf("path_pattern %q resolves to the repository root", s.Config.PathPattern)
+	}
+	return path, nil
+}
+
+// Slug turns a branch name into a single path component: "feat/foo" becomes
+// "feat-foo". Characters other than letters, digits, '.' and '_' are
+// replaced by '-', runs of '-' collapse, and leading dots are dropped so the
+// result is never hidden, "." or "..".
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '.', r == '_':
+			b.WriteRune(r)
+			dash = false
+		default:
+			if !dash {
+				b.WriteByte('-')
+				dash = true
+			}
+		}
+	}
+	return strings.Trim(strings.TrimLeft(b.String(), ".-"), "-")
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// checkWorktreePath fails if path is already taken by another worktree or by
+// a non-empty directory, so a collision is reported before git runs.
+func (s *Service) checkWorktreePath(path string) error {
+	if worktrees, err := s.Git.WorktreeList(); err == nil {
+		for _, wt := range worktrees {
+			if filepath.Clean(wt.Path) == path {
+				return fmt.Errorf("%s is already used by worktree %s (branch %s)", path, wt.Name, wt.Branch)
+			}
+		}
+	}
+	entries, err := os.ReadDir(path)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty", path)
+	}
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return fmt.Errorf("%s already exists and is not a directory", path)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nicobailon/treemux/internal/sessions"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+// CaptureSession records the windows, panes, layouts and foreground commands
+// of the session for the worktree at path.
+func (s *Service) CaptureSession(name, path string, procs *tmux.ProcessTree) (sessions.Session, error) {
+	windows, err := s.Tmux.Windows(name)
+	if err != nil {
+		return sessions.Session{}, err
+	}
+	foreground := map[int]int{}
+	var pids []int
+	for _, w := range windows {
+		for _, p := range w.Panes {
+			if procs == nil || isShellProcess(p.Command) {
+				continue
+			}
+			if pid := foregroundPID(procs, p.PID); pid != 0 {
+				foreground[p.PID] = pid
+				pids = append(pids, pid)
+			}
+		}
+	}
+	commands := s.Tmux.CommandLines(pids)
+
+	saved := sessions.Session{Name: name, RepoRoot: s.Git.RepoRoot, Path: path, SavedAt: time.Now()}
+	for _, w := range windows {
+		sw := sessions.Window{Name: w.Name, Layout: w.Layout, Active: w.Active}
+		for _, p := range w.Panes {
+			sw.Panes = append(sw.Panes, sessions.Pane{Dir: p.Dir, Command: commands[foreground[p.PID]], Active: p.Active})
+		}
+		saved.Windows = append(saved.Windows, sw)
+	}
+	return saved, nil
+}
+
+// foregroundPID returns pid or its first descendant that is not a shell.
+func foregroundPID(procs *tmux.ProcessTree, pid int) int {
+	if !isShellProcess(procs.Name(pid)) {
+		return pid
+	}
+	for _, child := range procs.Children(pid) {
+		if fg := foregroundPID(procs, child); fg != 0 {
+			return fg
+		}
+	}
+	return 0
+}
+
+// SaveSessions saves every running session in states. Saved sessions of
+// worktrees without a running session are kept so they can be restored.
+func (s *Service) SaveSessions(states []WorktreeState) error {
+	procs, _ := s.Tmux.ProcessTree()
+	var captured []sessions.Session
+	for _, st := range states {
+		if !st.HasSession {
+			continue
+		}
+		if saved, err := s.CaptureSession(st.SessionName, st.Worktree.Path, procs); err == nil {
+			captured = append(captured, saved)
+		}
+	}
+	if len(captured) == 0 {
+		return nil
+	}
+	return sessions.Update(func(store *sessions.Store) {
+		for _, saved := range captured {
+			store.Put(saved)
+		}
+	})
+}
+
+// ForgetSession drops the saved session of the worktree at path, e.g. after
+// it was deleted or its session was killed on purpose.
+func (s *Service) ForgetSession(path string) {
+	store, err := sessions.Load()
+	if err != nil {
+		return
+	}
+	if _, ok := store.Get(path); !ok {
+		return
+	}
+	_ = sessions.Update(func(store *sessions.Store) {
+		store.Remove(path)
+	})
+}
+
+// moveSavedSession re-keys the saved session of a worktree moved from
+// oldPath to newPath.
+func (s *Service) moveSavedSession(oldPath, newPath, name string) error {
+	store, err := sessions.Load()
+	if err != nil {
+		return err
+	}
+	if _, ok := store.Get(oldPath); !ok {
+		return nil
+	}
+	return sessions.Update(func(store *sessions.Store) {
+		saved, ok := store.Get(oldPath)
+		if !ok {
+			return
+		}
+		store.Remove(oldPath)
+		saved.Path = newPath
+		saved.Name = name
+		for _, w := range saved.Windows {
+			for i, p := range w.Panes {
+				if rel, err := filepath.Rel(oldPath, p.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+					w.Panes[i].Dir = filepath.Join(newPath, rel)
+				}
+			}
+		}
+		store.Put(saved)
+	})
+}
+
+// RestoreSession rebuilds a saved session and returns its name. Sessions
+// without saved windows get the configured layout instead.
+func (s *Service) RestoreSession(saved sessions.Session) (string, error) {
+	name, running, err := s.SessionFor(saved.Path)
+	if err != nil {
+		return name, err
+	}
+	if running {
+		return name, fmt.Errorf("session %s is already running", name)
+	}
+	if len(saved.Windows) == 0 {
+		return name, s.StartSession(name, saved.Path)
+	}
+	if err := s.restoreWindows(name, saved); err != nil {
+		if s.Tmux.HasSession(name) {
+			_ = s.Tmux.KillSession(name)
+		}
+		return name, err
+	}
+	s.tagSession(name, saved.Path)
+	return name, nil
+}
+
+func (s *Service) restoreWindows(name string, saved sessions.Session) error {
+	created := false
+	activeWindow := ""
+	for _, w := range saved.Windows {
+		if len(w.Panes) == 0 {
+			continue
+		}
+		dir := restoreDir(saved.Path, w.Panes[0].Dir)
+		var windowID string
+		var err error
+		if !created {
+			windowID, err = s.Tmux.NewSessionWindow(name, dir, w.Name)
+			created = true
+		} else {
+			windowID, err = s.Tmux.NewWindow(name, w.Name, dir)
+		}
+		if err != nil {
+			return fmt.Errorf("restore window %q: %w", w.Name, err)
+		}
+		// The first pane is addressed through its window until a split
+		// makes another pane active.
+		targets := []string{windowID}
+		for _, p := range w.Panes[1:] {
+			paneID, err := s.Tmux.SplitWindow(windowID, restoreDir(saved.Path, p.Dir), false, "")
+			if err != nil {
+				return fmt.Errorf("restore window %q: split: %w", w.Name, err)
+			}
+			targets = append(targets, paneID)
+			// keep room for the next split
+			_ = s.Tmux.SelectLayout(windowID, "tiled")
+		}
+		if w.Layout != "" {
+			_ = s.Tmux.SelectLayout(windowID, w.Layout)
+		}
+		for i, p := range w.Panes {
+			if cmd := s.restoreCommand(p.Command); cmd != "" {
+				if err := s.Tmux.SendKeys(targets[i], cmd); err != nil {
+					return fmt.Errorf("restore window %q: %w", w.Name, err)
+				}
+			}
+		}
+		for i, p := range w.Panes {
+			if p.Active && i > 0 {
+				_ = s.Tmux.SelectPane(targets[i])
+			}
+		}
+		if w.Active {
+			activeWindow = windowID
+		}
+	}
+	if activeWindow != "" {
+		return s.Tmux.SelectWindow(activeWindow)
+	}
+	return nil
+}
+
+// restoreDir falls back to the worktree root for directories that no
+// longer exist.
+func restoreDir(root, dir string) string {
+	if dir == "" {
+		return root
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return root
+	}
+	return dir
+}
+
+// restoreCommand returns cmd if its program is allowed by restore.commands.
+func (s *Service) restoreCommand(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+	program := filepath.Base(fields[0])
+	for _, allowed := range s.Config.Restore.Commands {
+		if allowed == "*" || allowed == program {
+			return cmd
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"path/filepath"
+	"time"
+)
+
+type PruneReason string
+
+const (
+	PruneMerged PruneReason = "merged"
+	PruneGone   PruneReason = "gone"
+	PruneStale  PruneReason = "stale"
+	PruneActive PruneReason = "active"
+)
+
+const DefaultStaleAfter = 4 * 7 * 24 * time.Hour
+
+// PruneCandidate is a worktree together with the reason it can (or cannot)
+// be pruned.
+type PruneCandidate struct {
+	State        WorktreeState
+	Reason       PruneReason
+	Branch       *BranchInfo
+	LastActivity time.Time
+	// SquashMerged is set for a gone branch whose changes are already in
+	// the base branch.
+	SquashMerged bool
+}
+
+// DeleteOptions returns how the candidate is deleted when pruned. Branches
+// whose upstream is gone are force deleted only when they were
+// squash-merged; otherwise, like stale branches, they are only deleted if
+// merged and kept when they hold commits the base branch lacks.
+func (c PruneCandidate) DeleteOptions(keepBranch bool) DeleteOptions {
+	opts := DeleteOptions{Branch: DeleteMergedBranch}
+	switch {
+	case keepBranch:
+		opts.Branch = KeepBranch
+	case c.Reason == PruneGone && c.SquashMerged:
+		opts.Branch = ForceDeleteBranch
+	}
+	return opts
+}
+
+// Classify sorts every worktree into merged, gone-upstream, stale or active.
+// The main and current worktrees, the base branch and worktrees that are locked or have
+// local changes or running processes are always active.
+func (s *Service) Classify(states []WorktreeState, staleAfter time.Duration) []PruneCandidate {
+	var out []PruneCandidate
+	for _, st := range states {
+		c := PruneCandidate{State: st, Reason: PruneActive}
+		c.Branch = s.BranchInfo(st.Worktree.Path)
+		c.LastActivity = s.Git.LastCommitTime(st.Worktree.Path)
+		if st.SessionInfo != nil && st.SessionInfo.LastActivity.After(c.LastActivity) {
+			c.LastActivity = st.SessionInfo.LastActivity
+		}
+		if !s.prunable(st, c.Branch) {
+			out = append(out, c)
+			continue
+		}
+		switch {
+		case c.Branch.Name != "" && c.Branch.Merged && !s.untouched(c.Branch):
+			c.Reason = PruneMerged
+		case c.Branch.Name != "" && s.Git.UpstreamGone(c.Branch.Name):
+			c.Reason = PruneGone
+			c.SquashMerged = s.squashMerged(c.Branch)
+		case staleAfter > 0 && !c.LastActivity.IsZero() && time.Since(c.LastActivity) > staleAfter:
+			c.Reason = PruneStale
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (s *Service) prunable(st WorktreeState, branch *BranchInfo) bool {
+	if filepath.Clean(st.Worktree.Path) == filepath.Clean(s.mainWorktree()) || st.Worktree.Path == s.Git.RepoRoot || branch.Name == branch.Base || st.Worktree.Locked {
+		return false
+	}
+	if st.Status != nil && !st.Status.Clean {
+		return false
+	}
+	if st.HasSession {
+		for _, p := range st.Processes {
+			if !isShellProcess(p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// squashMerged reports whether the changes of branch were squash-merged
+// into the base branch, locally or on origin.
+func (s *Service) squashMerged(branch *BranchInfo) bool {
+	if s.Git.IsSquashMerged(branch.Name, branch.Base) {
+		return true
+	}
+	origin := "refs/remotes/origin/" + branch.Base
+	return s.Git.RefExists(origin) && s.Git.IsSquashMerged(branch.Name, origin)
+}
+
+// untouched reports whether the branch still points at the base branch's
+// commit and never moved, i.e. it was created but never committed to. Such
+// branches count as merged but are more likely new work than finished work.
+func (s *Service) untouched(branch *BranchInfo) bool {
+	tip := s.Git.ResolveRef("refs/heads/" + branch.Name)
+	return tip != "" && tip == s.Git.ResolveRef(branch.Base) && !s.Git.BranchMoved(branch.Name)
+}
+
+// Prune deletes the candidate's worktree, session and (unless keepBranch)
+// branch through DeleteWorktree.
+func (s *Service) Prune(c PruneCandidate, keepBranch bool) (*DeleteResult, error) {
+	return s.DeleteWorktree(c.State.Worktree.Path, c.DeleteOptions(keepBranch))
+}
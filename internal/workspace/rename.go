@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/recent"
+	"github.com/nicobailon/treemux/internal/tmux"
+)
+
+type RenameOptions struct {
+	// KeepBranch leaves the branch alone and only moves the folder and
+	// session.
+	KeepBranch bool
+	// Recent is updated and saved in place of recent.json on disk, so a
+	// caller holding the store does not overwrite the change later.
+	Recent *recent.Store
+}
+
+// RenameResult is what a rename changed. Unchanged parts repeat the old
+// value.
+type RenameResult struct {
+	OldPath, Path       string
+	OldBranch, Branch   string
+	OldSession, Session string
+}
+
+// Rename moves the worktree at path to the location of newName, renames its
+// branch to newName and renames its session to match. The steps run as one
+// unit: when one fails, the ones before it are undone.
+func (s *Service) Rename(path, newName string, opts RenameOptions) (*RenameResult, error) {
+	wt, err := s.FindWorktree(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Clean(wt.Path) == filepath.Clean(s.mainWorktree()) {
+		return nil, fmt.Errorf("cannot rename the main worktree")
+	}
+	newPath, err := s.WorktreePath(newName)
+	if err != nil {
+		return nil, err
+	}
+	result := &RenameResult{OldPath: wt.Path, Path: newPath, OldBranch: wt.Branch, Branch: wt.Branch}
+	if !opts.KeepBranch && wt.Branch != "" && wt.Branch != newName {
+		if s.Git.BranchExists(newName) {
+			return nil, fmt.Errorf("branch %s already exists", newName)
+		}
+		result.Branch = newName
+	}
+	if newPath == wt.Path && result.Branch == wt.Branch {
+		return nil, fmt.Errorf("%s is already named %s", wt.Name, newName)
+	}
+	if newPath != wt.Path {
+		if err := s.checkWorktreePath(newPath); err != nil {
+			return nil, err
+		}
+	}
+
+	snap := s.Tmux.Snapshot()
+	oldSession, running := s.MatchSession(snap, *wt)
+	result.OldSession, result.Session = oldSession, oldSession
+	var oldOwner tmux.Owner
+	if running {
+		oldOwner = snap.Sessions[oldSession].Owner
+		// Keep a custom name given with tmux rename-session.
+		if oldSession == s.SessionNameFor(*wt) {
+			result.Session = s.SessionNameFor(git.Worktree{Path: newPath, Branch: result.Branch})
+		}
+		if result.Session != oldSession && snap.Has(result.Session) {
+			return nil, fmt.Errorf("session %s already exists", result.Session)
+		}
+	}
+
+	// Git commands run from the main worktree, which cannot be the one
+	// being moved.
+	g := &git.Git{RepoRoot: s.mainWorktree(), Cmd: s.Git.Cmd}
+	j := newJournal("rename " + wt.Name)
+	if newPath != wt.Path {
+		err := j.run("move worktree", func() error {
+			return g.WorktreeMove(wt.Path, newPath)
+		}, func() error {
+			return g.WorktreeMove(newPath, wt.Path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result.Branch != wt.Branch {
+		err := j.run("rename branch", func() error {
+			return g.RenameBranch(wt.Branch, result.Branch)
+		}, func() error {
+			return g.RenameBranch(result.Branch, wt.Branch)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if running {
+		if result.Session != oldSession {
+			err := j.run("rename session", func() error {
+				return s.Tmux.RenameSession(oldSession, result.Session)
+			}, func() error {
+				return s.Tmux.RenameSession(result.Session, oldSession)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		_ = j.run("tag session", func() error {
+			s.tagSession(result.Session, newPath)
+			return nil
+		}, func() error {
+			return s.Tmux.SetOwner(oldSession, oldOwner)
+		})
+	}
+	err = j.run("update saved session", func() error {
+		return s.moveSavedSession(wt.Path, newPath, result.Session)
+	}, func() error {
+		return s.moveSavedSession(newPath, wt.Path, oldSession)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = j.run("update recent worktrees", func() error {
+		return moveRecent(opts.Recent, wt.Path, newPath, filepath.Base(newPath), result.Session)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Invalidate(wt.Path)
+	return result, nil
+}
+
+// moveRecent points recent entries at the renamed worktree. store is loaded
+// from disk when nil.
+func moveRecent(store *recent.Store, oldPath, newPath, name, sessionName string) error {
+	if store == nil {
+		var err error
+		if store, err = recent.Load(); err != nil {
+			return err
+		}
+	}
+	before := append([]recent.Entry(nil), store.Entries...)
+	if !store.Move(oldPath, newPath, name, sessionName) {
+		return nil
+	}
+	if err := store.Save(); err != nil {
+		store.Entries = before
+		return err
+	}
+	return nil
+}
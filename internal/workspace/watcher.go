@@ -0,0 +1,271 @@
+package workspace
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nicobailon/treemux/internal/git"
+)
+
+// watchDebounce groups the events of one save, checkout or commit.
+const watchDebounce = 200 * time.Millisecond
+
+// maxIgnoreCheck bounds the files passed to one git check-ignore; larger
+// batches are assumed to matter.
+const maxIgnoreCheck = 200
+
+// Watcher reports worktrees whose git state may have changed. It watches the
+// gitdir of each worktree (index, HEAD and the HEAD reflog) and every
+// directory of its working tree that .gitignore does not exclude.
+type Watcher struct {
+	svc     *Service
+	fs      *fsnotify.Watcher
+	changes chan string
+	done    chan struct{}
+
+	mu      sync.Mutex
+	roots   map[string]bool
+	dirs    map[string]string // watched directory -> worktree
+	gitDirs map[string]string
+	pending map[string][]string // worktree -> changed files, "" for git metadata
+}
+
+func (s *Service) NewWatcher() (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		svc:     s,
+		fs:      fw,
+		changes: make(chan string, 16),
+		done:    make(chan struct{}),
+		roots:   map[string]bool{},
+		dirs:    map[string]string{},
+		gitDirs: map[string]string{},
+		pending: map[string][]string{},
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Changes receives the path of each worktree that changed.
+func (w *Watcher) Changes() <-chan string {
+	return w.changes
+}
+
+// Set makes paths the watched worktrees, adding new ones and dropping the
+// rest.
+func (w *Watcher) Set(paths []string) {
+	want := map[string]bool{}
+	for _, p := range paths {
+		want[p] = true
+	}
+	w.mu.Lock()
+	var added []string
+	for p := range want {
+		if !w.roots[p] {
+			w.roots[p] = true
+			added = append(added, p)
+		}
+	}
+	for p := range w.roots {
+		if !want[p] {
+			w.removeLocked(p)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, p := range added {
+		w.addWorktree(p)
+	}
+}
+
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.fs.Close()
+}
+
+func (w *Watcher) addWorktree(root string) {
+	gitDir := filepath.Dir(git.IndexPath(root))
+	ignored, _ := w.svc.Git.IgnoredDirs(root)
+	dirs := w.walk(root, ignored)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.roots[root] {
+		return
+	}
+	for _, dir := range []string{gitDir, filepath.Join(gitDir, "logs")} {
+		if w.fs.Add(dir) == nil {
+			w.gitDirs[dir] = root
+		}
+	}
+	for _, dir := range dirs {
+		if w.fs.Add(dir) == nil {
+			w.dirs[dir] = root
+		}
+	}
+}
+
+// walk lists the directories under dir, skipping .git, ignored directories
+// and nested repositories or worktrees.
+func (w *Watcher) walk(dir string, ignored []string) []string {
+	root := dir
+	skip := map[string]bool{}
+	for _, rel := range ignored {
+		skip[filepath.Join(root, rel)] = true
+	}
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" || skip[path] {
+			return filepath.SkipDir
+		}
+		if path != root {
+			if _, err := os.Lstat(filepath.Join(path, ".git")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs
+}
+
+func (w *Watcher) removeLocked(root string) {
+	delete(w.roots, root)
+	delete(w.pending, root)
+	for _, m := range []map[string]string{w.dirs, w.gitDirs} {
+		for dir, wt := range m {
+			if wt == root {
+				w.fs.Remove(dir)
+				delete(m, dir)
+			}
+		}
+	}
+}
+
+func (w *Watcher) loop() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case _, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) handle(ev fsnotify.Event) {
+	if ev.Op == fsnotify.Chmod {
+		return
+	}
+	dir, name := filepath.Split(ev.Name)
+	dir = filepath.Clean(dir)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if root, ok := w.gitDirs[dir]; ok {
+		if name == "index" || name == "HEAD" {
+			w.queueLocked(root, "")
+		}
+		return
+	}
+	root, ok := w.dirs[dir]
+	if !ok {
+		return
+	}
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		if _, watched := w.dirs[ev.Name]; watched {
+			delete(w.dirs, ev.Name)
+		}
+	}
+	if ev.Has(fsnotify.Create) && name != ".git" {
+		if fi, err := os.Lstat(ev.Name); err == nil && fi.IsDir() {
+			go w.addDir(root, ev.Name)
+		}
+	}
+	w.queueLocked(root, ev.Name)
+}
+
+// addDir starts watching a directory created after the worktree was added,
+// unless it is ignored.
+func (w *Watcher) addDir(root, dir string) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || len(w.svc.Git.Ignored(root, []string{rel + "/"})) > 0 {
+		return
+	}
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		return
+	}
+	dirs := w.walk(dir, nil)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.roots[root] {
+		return
+	}
+	for _, d := range dirs {
+		if w.fs.Add(d) == nil {
+			w.dirs[d] = root
+		}
+	}
+}
+
+func (w *Watcher) queueLocked(root, file string) {
+	files, scheduled := w.pending[root]
+	w.pending[root] = append(files, file)
+	if !scheduled {
+		time.AfterFunc(watchDebounce, func() { w.flush(root) })
+	}
+}
+
+// flush reports root unless every changed file is ignored.
+func (w *Watcher) flush(root string) {
+	w.mu.Lock()
+	files, ok := w.pending[root]
+	delete(w.pending, root)
+	w.mu.Unlock()
+	if !ok || !w.relevant(root, files) {
+		return
+	}
+	select {
+	case w.changes <- root:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) relevant(root string, files []string) bool {
+	if len(files) > maxIgnoreCheck {
+		return true
+	}
+	seen := map[string]bool{}
+	var rels []string
+	for _, f := range files {
+		if f == "" {
+			return true
+		}
+		rel, err := filepath.Rel(root, f)
+		if err != nil {
+			return true
+		}
+		if !seen[rel] {
+			seen[rel] = true
+			rels = append(rels, rel)
+		}
+	}
+	return len(w.svc.Git.Ignored(root, rels)) < len(rels)
+}
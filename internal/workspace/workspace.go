@@ -2,9 +2,14 @@ package workspace
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/nicobailon/treemux/internal/config"
 	"github.com/nicobailon/treemux/internal/git"
@@ -17,6 +22,17 @@ type Service struct {
 	Tmux   *tmux.Tmux
 	Config *config.Config
 	Cmd    shell.Commander
+	// HookLog receives hook output; nil means hooks.log in the config dir.
+	HookLog io.Writer
+	// ConfigWarning is set when part of the repository's config was
+	// ignored, e.g. the hooks of an untrusted .treemux.yaml.
+	ConfigWarning error
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedGitState
+
+	mainOnce sync.Once
+	mainRoot string
 }
 
 type WorktreeState struct {
@@ -32,33 +48,17 @@ type WorktreeState struct {
 }
 
 func NewService(g *git.Git, t *tmux.Tmux, cfg *config.Config, cmd shell.Commander) *Service {
-	return &Service{Git: g, Tmux: t, Config: cfg, Cmd: cmd}
-}
-
-func (s *Service) WorktreePath(name string) string {
-	repo := s.Git.RepoRoot
-	parent := filepath.Dir(repo)
-	repoName := filepath.Base(repo)
-	switch s.Config.PathPattern {
-	case "subdirectory":
-		return filepath.Join(repo, ".worktrees", name)
-	default:
-		return filepath.Join(parent, repoName+"-"+name)
+	var warning error
+	if cfg != nil && g != nil {
+		cfg, _, warning = cfg.Resolve(g.RepoRoot)
 	}
+	return &Service{Git: g, Tmux: t, Config: cfg, Cmd: cmd, ConfigWarning: warning}
 }
 
-func (s *Service) SessionName(wtPath string) string {
-	switch s.Config.SessionName {
-	case "branch":
-		out, err := s.Cmd.Run("git", "-C", wtPath, "branch", "--show-current")
-		if err == nil {
-			branch := strings.TrimSpace(string(out))
-			if branch != "" {
-				return branch
-			}
-		}
-	}
-	return filepath.Base(wtPath)
+// NewServiceForRepo builds a Service for a repository other than the one
+// treemux was launched from, e.g. a repo picked in global mode.
+func NewServiceForRepo(repoRoot string, t *tmux.Tmux, cfg *config.Config, cmd shell.Commander) *Service {
+	return NewService(&git.Git{RepoRoot: repoRoot, Cmd: cmd}, t, cfg, cmd)
 }
 
 func (s *Service) List() ([]WorktreeState, []string, error) {
@@ -66,45 +66,16 @@ func (s *Service) List() ([]WorktreeState, []string, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	snap := s.Tmux.Snapshot()
+	states := s.collect(worktrees, snap)
 
-	sessions, _ := s.Tmux.ListSessions()
-	sessionSet := map[string]struct{}{}
-	for _, sess := range sessions {
-		sessionSet[sess.Name] = struct{}{}
-	}
-
-	var states []WorktreeState
-	for _, wt := range worktrees {
-		sessionName := s.SessionName(wt.Path)
-		_, has := sessionSet[sessionName]
-		status, _ := s.Git.Status(wt.Path)
-		ahead, behind := s.aheadBehind(wt.Path)
-		commits, _ := s.Git.Log(wt.Path, 6)
-		info, _ := s.Tmux.SessionInfo(sessionName)
-		procs, _ := s.Tmux.RunningProcesses(sessionName)
-		states = append(states, WorktreeState{
-			Worktree:    wt,
-			SessionName: sessionName,
-			HasSession:  has,
-			Status:      status,
-			Ahead:       ahead,
-			Behind:      behind,
-			Commits:     commits,
-			SessionInfo: info,
-			Processes:   procs,
-		})
-	}
-
-	orphans := []string{}
-	worktreeNames := map[string]struct{}{}
-	for _, wt := range worktrees {
-		worktreeNames[wt.Name] = struct{}{}
-	}
-	for name := range sessionSet {
-		if _, ok := worktreeNames[name]; !ok {
-			orphans = append(orphans, name)
+	claimed := map[string]bool{}
+	for _, st := range states {
+		if st.HasSession {
+			claimed[st.SessionName] = true
 		}
 	}
+	orphans := Orphans(snap, claimed, map[string]bool{s.mainWorktree(): true})
 
 	return states, orphans, nil
 }
@@ -119,66 +90,453 @@ func (s *Service) WorktreesWithoutSession(states []WorktreeState) []WorktreeStat
 	return missing
 }
 
+type CreateOptions struct {
+	// Base is the branch the new branch starts from; empty means BaseBranch().
+	Base string
+	// ExistingBranch checks out the existing branch named like the worktree
+	// instead of creating it.
+	ExistingBranch bool
+	// RemoteBranch creates the branch tracking a remote branch such as
+	// origin/feature.
+	RemoteBranch string
+	// Ref fetches a ref such as refs/pull/123/head from Remote (default
+	// origin) into the new branch.
+	Ref       string
+	Remote    string
+	NoSession bool
+}
+
 func (s *Service) CreateWorktree(name, baseBranch string) (string, error) {
-	path := s.WorktreePath(name)
-	if err := s.Git.WorktreeAdd(path, name, baseBranch); err != nil {
-		return "", err
+	path, _, err := s.Create(name, CreateOptions{Base: baseBranch})
+	return path, err
+}
+
+// Create adds a worktree for branch name and starts its session. It returns
+// the worktree path and the session name ("" with NoSession).
+func (s *Service) Create(name string, opts CreateOptions) (string, string, error) {
+	path, err := s.WorktreePath(name)
+	if err != nil {
+		return "", "", err
 	}
-	sessionName := s.SessionName(path)
-	_ = s.Tmux.NewSession(sessionName, path)
-	return path, nil
+	if err := s.checkWorktreePath(path); err != nil {
+		return "", "", err
+	}
+	var add func() error
+	switch {
+	case opts.ExistingBranch:
+		add = func() error { return s.Git.WorktreeAddExisting(path, name) }
+	case opts.RemoteBranch != "":
+		add = func() error { return s.Git.WorktreeAddTracking(path, name, opts.RemoteBranch) }
+	case opts.Ref != "":
+		remote := opts.Remote
+		if remote == "" {
+			remote = "origin"
+		}
+		add = func() error { return s.Git.WorktreeAddRef(path, name, remote, opts.Ref) }
+	default:
+		base := opts.Base
+		if base == "" {
+			base = s.BaseBranch()
+		}
+		add = func() error { return s.Git.WorktreeAdd(path, name, base) }
+	}
+	j := newJournal("create " + name)
+	if err := s.addWorktreeStep(j, path, name, add); err != nil {
+		return "", "", err
+	}
+	sessionName := ""
+	if !opts.NoSession {
+		sessionName = s.SessionName(path)
+		err := j.run("start session", func() error {
+			return s.StartSession(sessionName, path)
+		}, nil)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if err := s.runHooks(HookPostCreate, hookTarget{Path: path, Branch: name, Session: sessionName}); err != nil {
+		return path, sessionName, err
+	}
+	return path, sessionName, nil
 }
 
-func (s *Service) DeleteWorktree(path string, force bool) error {
-	sessionName := s.SessionName(path)
-	_ = s.Tmux.KillSession(sessionName)
-	return s.Git.WorktreeRemove(path, force)
+// addWorktreeStep runs add, which creates the worktree at path on branch, as
+// the first step of j. Whatever a failed add left behind is cleaned up, and
+// undoing removes the worktree, its directory and the branch if add created
+// it.
+func (s *Service) addWorktreeStep(j *journal, path, branch string, add func() error) error {
+	branchExisted := s.Git.BranchExists(branch)
+	_, statErr := os.Stat(path)
+	dirExisted := statErr == nil
+	undo := func() error {
+		var errs []error
+		if err := s.Git.WorktreeRemove(path, true); err != nil {
+			if !dirExisted {
+				err = os.RemoveAll(path)
+			}
+			_ = s.Git.WorktreePrune()
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if !branchExisted && s.Git.BranchExists(branch) {
+			errs = append(errs, s.Git.DeleteBranch(branch, true))
+		}
+		return errors.Join(errs...)
+	}
+	return j.run("add worktree", func() error {
+		if err := add(); err != nil {
+			_ = undo()
+			return err
+		}
+		return nil
+	}, undo)
 }
 
-func (s *Service) KillSession(name string) error {
-	if !s.Tmux.HasSession(name) {
-		return errors.New("session not found")
+// BaseBranch is the configured base_branch when it exists locally, otherwise
+// the remote's default branch.
+func (s *Service) BaseBranch() string {
+	if s.Config.BaseBranch != "" && s.Git.BranchExists(s.Config.BaseBranch) {
+		return s.Config.BaseBranch
 	}
-	return s.Tmux.KillSession(name)
+	return s.Git.DefaultBranch()
 }
 
-func (s *Service) Jump(name, path string) error {
-	sessionName := s.SessionName(path)
-	if !s.Tmux.HasSession(sessionName) {
-		if err := s.Tmux.NewSession(sessionName, path); err != nil {
+type BranchCleanup int
+
+const (
+	KeepBranch BranchCleanup = iota
+	// DeleteMergedBranch deletes the branch only if it is merged into the
+	// base branch.
+	DeleteMergedBranch
+	ForceDeleteBranch
+)
+
+type DeleteOptions struct {
+	// Force removes the worktree even if it has local changes.
+	Force  bool
+	Branch BranchCleanup
+	// DeleteRemote also deletes the branch's upstream once the local branch
+	// has been deleted.
+	DeleteRemote bool
+}
+
+// BranchInfo describes the branch of a worktree that is about to be deleted.
+type BranchInfo struct {
+	Name         string
+	Base         string
+	Merged       bool
+	Remote       string
+	RemoteBranch string
+}
+
+// DeleteResult reports what happened to the worktree's branch.
+type DeleteResult struct {
+	Branch        string
+	BranchDeleted bool
+	// DeletedRemote is the remote branch that was deleted, e.g. origin/foo.
+	DeletedRemote string
+	// KeptReason explains why a branch that was asked to be deleted was kept.
+	KeptReason string
+}
+
+// BranchInfo returns the branch checked out at path and whether it is merged
+// into the base branch (locally or on origin).
+func (s *Service) BranchInfo(path string) *BranchInfo {
+	info := &BranchInfo{Name: s.branchAt(path), Base: s.BaseBranch()}
+	if info.Name == "" {
+		return info
+	}
+	info.Merged = s.Git.IsMerged(info.Name, info.Base)
+	if !info.Merged && s.Git.RefExists("refs/remotes/origin/"+info.Base) {
+		info.Merged = s.Git.IsMerged(info.Name, "refs/remotes/origin/"+info.Base)
+	}
+	info.Remote, info.RemoteBranch, _ = s.Git.Upstream(info.Name)
+	return info
+}
+
+func (s *Service) DeleteWorktree(path string, opts DeleteOptions) (*DeleteResult, error) {
+	sessionName, running, _ := s.SessionFor(path)
+	branch := s.BranchInfo(path)
+	result := &DeleteResult{Branch: branch.Name}
+	target := hookTarget{Path: path, Branch: branch.Name, Session: sessionName}
+	if filepath.Clean(path) == filepath.Clean(s.mainWorktree()) {
+		return result, fmt.Errorf("cannot delete the main worktree")
+	}
+	// A locked worktree is refused even with Force, before hooks run or the
+	// session is killed.
+	if wt, err := s.FindWorktree(path); err == nil && wt.Locked {
+		if wt.LockReason != "" {
+			return result, fmt.Errorf("%w: %s", git.ErrWorktreeLocked, wt.LockReason)
+		}
+		return result, git.ErrWorktreeLocked
+	}
+	if err := s.runHooks(HookPreDelete, target); err != nil {
+		return result, err
+	}
+	j := newJournal("delete " + filepath.Base(path))
+	if !opts.Force {
+		// git worktree remove refuses these; find out before the session
+		// is gone.
+		err := j.run("check worktree", func() error {
+			st, err := s.Git.Status(path)
+			if err != nil || st.Clean {
+				return nil
+			}
+			return fmt.Errorf("%w: %d conflicted, %d staged, %d modified and %d untracked files", git.ErrDirtyWorktree, st.Conflicted, st.Staged, st.Modified, st.Untracked)
+		}, nil)
+		if err != nil {
+			return result, err
+		}
+	}
+	if running {
+		procs, _ := s.Tmux.ProcessTree()
+		saved, captureErr := s.CaptureSession(sessionName, path, procs)
+		err := j.run("kill session", func() error {
+			if err := s.Tmux.KillSession(sessionName); !errors.Is(err, tmux.ErrSessionNotFound) {
+				return err
+			}
+			return nil
+		}, func() error {
+			if captureErr != nil {
+				return s.StartSession(sessionName, path)
+			}
+			_, err := s.RestoreSession(saved)
 			return err
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	err := j.run("remove worktree", func() error {
+		return s.Git.WorktreeRemove(path, opts.Force)
+	}, nil)
+	if err != nil {
+		return result, err
+	}
+	// The worktree is gone; later failures cannot bring it back.
+	j.commit()
+	s.Invalidate(path)
+	s.ForgetSession(path)
+	if err := s.cleanupBranch(branch, opts, result); err != nil {
+		return result, err
+	}
+	return result, s.runHooks(HookPostDelete, target)
+}
+
+func (s *Service) cleanupBranch(branch *BranchInfo, opts DeleteOptions, result *DeleteResult) error {
+	if opts.Branch == KeepBranch || branch.Name == "" {
+		return nil
+	}
+	switch {
+	case branch.Name == branch.Base:
+		result.KeptReason = fmt.Sprintf("%s is the base branch", branch.Name)
+		return nil
+	case opts.Branch == DeleteMergedBranch && !branch.Merged:
+		result.KeptReason = fmt.Sprintf("%s is not merged into %s", branch.Name, branch.Base)
+		return nil
+	}
+	// git branch -d compares against HEAD rather than the base branch, so
+	// merged branches are deleted with -D as well.
+	if err := s.Git.DeleteBranch(branch.Name, true); err != nil {
+		return fmt.Errorf("worktree removed but branch %q was not deleted: %w", branch.Name, err)
+	}
+	result.BranchDeleted = true
+	if opts.DeleteRemote && branch.Remote != "" {
+		if err := s.Git.DeleteRemoteBranch(branch.Remote, branch.RemoteBranch); err != nil {
+			return fmt.Errorf("branch %q deleted but %s/%s was not: %w", branch.Name, branch.Remote, branch.RemoteBranch, err)
 		}
+		result.DeletedRemote = branch.Remote + "/" + branch.RemoteBranch
+	}
+	return nil
+}
+
+// DeleteCheck describes what would be lost by deleting a worktree.
+type DeleteCheck struct {
+	Worktree    git.Worktree
+	SessionName string
+	HasSession  bool
+	Status      *git.StatusSummary
+	Unpushed    int
+	Processes   []string
+}
+
+// Problems lists the reasons the worktree should not be deleted without
+// --force. A locked worktree is refused even with it.
+func (c *DeleteCheck) Problems() []string {
+	var problems []string
+	if c.Worktree.Locked {
+		if c.Worktree.LockReason != "" {
+			problems = append(problems, "locked: "+c.Worktree.LockReason)
+		} else {
+			problems = append(problems, "locked")
+		}
+	}
+	if c.Status != nil && !c.Status.Clean {
+		problems = append(problems, fmt.Sprintf("uncommitted changes (%d conflicted, %d staged, %d modified, %d untracked)",
+			c.Status.Conflicted, c.Status.Staged, c.Status.Modified, c.Status.Untracked))
+	}
+	if c.Unpushed > 0 {
+		problems = append(problems, fmt.Sprintf("%d unpushed commit(s)", c.Unpushed))
+	}
+	if len(c.Processes) > 0 {
+		problems = append(problems, "running processes: "+strings.Join(c.Processes, ", "))
+	}
+	return problems
+}
+
+// CheckDelete gathers the state that makes deleting the worktree at path
+// unsafe: a lock, local changes, unpushed commits and non-shell processes.
+func (s *Service) CheckDelete(path string) (*DeleteCheck, error) {
+	wt, err := s.FindWorktree(path)
+	if err != nil {
+		return nil, err
+	}
+	check := &DeleteCheck{Worktree: *wt}
+	name, running, _ := s.SessionFor(wt.Path)
+	check.SessionName = name
+	check.Status, err = s.Git.Status(wt.Path)
+	if err != nil {
+		return nil, err
+	}
+	check.Unpushed = s.unpushed(wt.Path, check.Status)
+	if running {
+		check.HasSession = true
+		procs, _ := s.Tmux.RunningProcesses(check.SessionName)
+		for _, p := range procs {
+			if !isShellProcess(p) {
+				check.Processes = append(check.Processes, p)
+			}
+		}
+		sort.Strings(check.Processes)
+	}
+	return check, nil
+}
+
+// FindWorktree resolves a worktree by path, folder name or branch.
+func (s *Service) FindWorktree(query string) (*git.Worktree, error) {
+	worktrees, err := s.Git.WorktreeList()
+	if err != nil {
+		return nil, err
+	}
+	abs, _ := filepath.Abs(query)
+	for _, wt := range worktrees {
+		if wt.Path == query || wt.Path == abs {
+			return &wt, nil
+		}
+	}
+	for _, wt := range worktrees {
+		if wt.Name == query || wt.Branch == query {
+			return &wt, nil
+		}
+	}
+	return nil, fmt.Errorf("no worktree matching %q", query)
+}
+
+// unpushed counts commits not on the upstream branch, as reported by
+// status. Without an upstream it counts commits not on any remote, and 0
+// when there are no remotes.
+func (s *Service) unpushed(path string, status *git.StatusSummary) int {
+	if status.Upstream != "" && !status.UpstreamGone {
+		return status.Ahead
+	}
+	if !s.Git.HasRemotes() {
+		return 0
+	}
+	out, err := s.Cmd.Run("git", "-C", path, "rev-list", "--count", "HEAD", "--not", "--remotes")
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	return n
+}
+
+var shellProcesses = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "fish": true, "dash": true,
+	"ksh": true, "tcsh": true, "csh": true, "nu": true, "login": true, "tmux": true,
+}
+
+func isShellProcess(name string) bool {
+	return shellProcesses[strings.TrimPrefix(filepath.Base(name), "-")]
+}
+
+// KillSession kills the named session and forgets its saved layout so it is
+// not restored later.
+func (s *Service) KillSession(name string) error {
+	info, ok := s.Tmux.Snapshot().Sessions[name]
+	if !ok {
+		return fmt.Errorf("%s: %w", name, tmux.ErrSessionNotFound)
+	}
+	if err := s.Tmux.KillSession(name); err != nil {
+		return err
+	}
+	if info.Owner.Managed() {
+		s.ForgetSession(info.Owner.Worktree)
+	} else if worktrees, err := s.Git.WorktreeList(); err == nil {
+		for _, wt := range worktrees {
+			if s.SessionName(wt.Path) == name {
+				s.ForgetSession(wt.Path)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) Jump(name, path string) error {
+	sessionName, err := s.PrepareJump(path)
+	if err != nil {
+		return err
 	}
 	return s.Tmux.SwitchClient(sessionName)
 }
 
+// PrepareJump makes sure the worktree's session exists and runs the on_jump
+// hooks. A failing hook is logged but does not prevent the jump.
+func (s *Service) PrepareJump(path string) (string, error) {
+	sessionName, running, err := s.SessionFor(path)
+	if err != nil {
+		return "", err
+	}
+	if running {
+		// Tags sessions started before treemux tagged them and keeps the
+		// branch current.
+		s.tagSession(sessionName, path)
+	} else if err := s.StartSession(sessionName, path); err != nil && !errors.Is(err, tmux.ErrSessionExists) {
+		// A session started since SessionFor looked is as good as ours.
+		return "", err
+	}
+	_ = s.runHooks(HookOnJump, hookTarget{Path: path, Branch: s.branchAt(path), Session: sessionName})
+	return sessionName, nil
+}
+
 func (s *Service) SwitchSession(name string) error {
 	return s.Tmux.SwitchClient(name)
 }
 
 func (s *Service) AdoptOrphan(sessionName, baseBranch string) (string, error) {
-	path := s.WorktreePath(sessionName)
-	if err := s.Git.WorktreeAdd(path, sessionName, baseBranch); err != nil {
+	path, err := s.WorktreePath(sessionName)
+	if err != nil {
 		return "", err
 	}
-	_, _ = s.Cmd.Run("tmux", "send-keys", "-t", sessionName, "cd '"+path+"'", "Enter")
-	return path, nil
-}
-
-func (s *Service) aheadBehind(path string) (int, int) {
-	ahead := 0
-	behind := 0
-	_, err := s.Cmd.Run("git", "-C", path, "rev-parse", "--abbrev-ref", "@{upstream}")
+	if err := s.checkWorktreePath(path); err != nil {
+		return "", err
+	}
+	j := newJournal("adopt " + sessionName)
+	err = s.addWorktreeStep(j, path, sessionName, func() error {
+		return s.Git.WorktreeAdd(path, sessionName, baseBranch)
+	})
 	if err != nil {
-		return ahead, behind
+		return "", err
 	}
-	if out, err := s.Cmd.Run("git", "-C", path, "rev-list", "--count", "@{upstream}..HEAD"); err == nil {
-		val, _ := strconv.Atoi(strings.TrimSpace(string(out)))
-		ahead = val
+	err = j.run("cd session", func() error {
+		return s.Tmux.SendKeys(sessionName, "cd '"+path+"'")
+	}, nil)
+	if err != nil {
+		return "", err
 	}
-	if out, err := s.Cmd.Run("git", "-C", path, "rev-list", "--count", "HEAD..@{upstream}"); err == nil {
-		val, _ := strconv.Atoi(strings.TrimSpace(string(out)))
-		behind = val
+	s.tagSession(sessionName, path)
+	if err := s.runHooks(HookPostAdopt, hookTarget{Path: path, Branch: sessionName, Session: sessionName}); err != nil {
+		return path, err
 	}
-	return ahead, behind
+	return path, nil
 }
@@ -1,13 +1,18 @@
 package workspace
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nicobailon/treemux/internal/config"
 	"github.com/nicobailon/treemux/internal/git"
+	"github.com/nicobailon/treemux/internal/recent"
+	"github.com/nicobailon/treemux/internal/sessions"
 	"github.com/nicobailon/treemux/internal/shell"
 	"github.com/nicobailon/treemux/internal/tmux"
 )
@@ -17,15 +22,100 @@ func TestWorktreePathPatterns(t *testing.T) {
 	g := &git.Git{RepoRoot: "/home/user/repo", Cmd: cmd}
 	cfg := &config.Config{PathPattern: "sibling"}
 	s := NewService(g, &tmux.Tmux{Cmd: cmd}, cfg, cmd)
+	t.Setenv("HOME", "/home/user")
 
-	if got := s.WorktreePath("feature"); got != "/home/user/repo-feature" {
-		t.Fatalf("sibling pattern mismatch: %s", got)
+	tests := []struct {
+		pattern, name, want string
+	}{
+		{"sibling", "feature", "/home/user/repo-feature"},
+		{"sibling", "feat/foo", "/home/user/repo-feat-foo"},
+		{"subdirectory", "feature", "/home/user/repo/.worktrees/feature"},
+		{"~/worktrees/{{.RepoName}}/{{.BranchSlug}}", "feat/foo", "/home/user/worktrees/repo/feat-foo"},
+		{"{{.RepoRoot}}-wt/{{.Name}}", "feat/foo", "/home/user/repo-wt/feat/foo"},
+		{"../wt/{{.BranchSlug}}", "fix: a b", "/home/user/wt/fix-a-b"},
 	}
+	for _, tt := range tests {
+		s.Config.PathPattern = tt.pattern
+		got, err := s.WorktreePath(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("WorktreePath(%q) with %q = %q, %v; want %q", tt.name, tt.pattern, got, err, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"nested", "{{.Missing}}", "{{.RepoRoot}}"} {
+		s.Config.PathPattern = pattern
+		if got, err := s.WorktreePath("feature"); err == nil {
+			t.Errorf("expected an error for %q, got %s", pattern, got)
+		}
+	}
+}
+
+func TestBareRepositoryWorktreePath(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	seed := filepath.Join(dir, "seed")
+	initRepo(t, seed)
+	bare := filepath.Join(dir, "proj", ".bare")
+	runGit(t, dir, "clone", "-q", "--bare", seed, bare)
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: bare, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{PathPattern: "sibling"}, cmd)
+	for pattern, want := range map[string]string{
+		"sibling":                       filepath.Join(dir, "proj", "feat-x"),
+		"subdirectory":                  filepath.Join(dir, "proj", "feat-x"),
+		"../wt/{{.RepoName}}-{{.Name}}": filepath.Join(dir, "proj", "wt", "proj-feat/x"),
+	} {
+		s.Config.PathPattern = pattern
+		if got, err := s.WorktreePath("feat/x"); err != nil || got != want {
+			t.Errorf("WorktreePath with %q = %q, %v; want %q", pattern, got, err, want)
+		}
+	}
+
+	named := filepath.Join(dir, "api.git")
+	runGit(t, dir, "clone", "-q", "--bare", seed, named)
+	s = NewService(&git.Git{RepoRoot: named, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{}, cmd)
+	for _, pattern := range []string{"sibling", "subdirectory"} {
+		s.Config.PathPattern = pattern
+		if got, err := s.WorktreePath("feat/x"); err != nil || got != filepath.Join(dir, "api-feat-x") {
+			t.Errorf("WorktreePath of name.git with %q = %q, %v", pattern, got, err)
+		}
+	}
+}
 
-	cfg.PathPattern = "subdirectory"
-	s.Config = cfg
-	if got := s.WorktreePath("feature"); got != "/home/user/repo/.worktrees/feature" {
-		t.Fatalf("subdirectory pattern mismatch: %s", got)
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"feature":        "feature",
+		"feat/foo":       "feat-foo",
+		"user/JIRA-12/x": "user-JIRA-12-x",
+		"../escape":      "escape",
+		".hidden":        "hidden",
+		"a  //  b":       "a-b",
+		"v1.2_rc":        "v1.2_rc",
+		"///":            "",
+	}
+	for in, want := range tests {
+		if got := Slug(in); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCreateDetectsPathCollision(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	initRepo(t, repo)
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{PathPattern: "sibling"}, cmd)
+	path, _, err := s.Create("feat/foo", CreateOptions{Base: "main", NoSession: true})
+	if err != nil || path != filepath.Join(dir, "repo-feat-foo") {
+		t.Fatalf("create: %s %v", path, err)
+	}
+	_, _, err = s.Create("feat-foo", CreateOptions{Base: "main", NoSession: true})
+	if err == nil || !strings.Contains(err.Error(), "already used by worktree") {
+		t.Fatalf("expected a collision error, got %v", err)
+	}
+	if !s.Git.BranchExists("feat/foo") || s.Git.BranchExists("feat-foo") {
+		t.Fatal("branch feat-foo was created despite the collision")
 	}
 }
 
@@ -46,16 +136,8 @@ func TestSessionNameFolder(t *testing.T) {
 
 func TestSessionNameBranch(t *testing.T) {
 	dir := t.TempDir()
-	run := func(args ...string) {
-		t.Helper()
-		cmd := execCommand(dir, args...)
-		if err := cmd.Run(); err != nil {
-			t.Fatalf("git %v: %v", args, err)
-		}
-	}
-	run("git", "init", "-q")
-	run("git", "commit", "--allow-empty", "-m", "init")
-	run("git", "checkout", "-b", "feature/test")
+	initRepo(t, dir)
+	runGit(t, dir, "checkout", "-b", "feature/test")
 
 	cmd := &shell.ExecCommander{}
 	g := &git.Git{RepoRoot: dir, Cmd: cmd}
@@ -66,8 +148,755 @@ func TestSessionNameBranch(t *testing.T) {
 	}
 }
 
-func execCommand(dir string, args ...string) *exec.Cmd {
-	cmd := exec.Command(args[0], args[1:]...)
+func TestSessionNameTemplate(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "api.v2")
+	initRepo(t, repo)
+	wt := filepath.Join(dir, "api-fix")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "fix/v1.2", wt)
+
+	cmd := &shell.ExecCommander{}
+	// A service started from the linked worktree still sees the main
+	// worktree's name, so every entry point agrees on the session name.
+	s := NewService(&git.Git{RepoRoot: wt, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{SessionName: "{{.RepoName}}/{{.Branch}}"}, cmd)
+	if got := s.SessionName(wt); got != "api_v2/fix/v1_2" {
+		t.Fatalf("session name mismatch: %s", got)
+	}
+	if got := s.SessionNameFor(git.Worktree{Path: repo, Branch: "main"}); got != "api_v2/main" {
+		t.Fatalf("session name mismatch: %s", got)
+	}
+
+	tests := []struct {
+		pattern string
+		vars    SessionVars
+		want    string
+	}{
+		{"folder", SessionVars{Name: "repo.js", Branch: "main"}, "repo_js"},
+		{"branch", SessionVars{Name: "wt", Branch: "feat:x"}, "feat_x"},
+		{"branch", SessionVars{Name: "wt"}, "wt"},
+		{"{{.RepoName}}-{{.BranchSlug}}", SessionVars{RepoName: "web", Name: "wt", Branch: "feat/a", BranchSlug: "feat-a"}, "web-feat-a"},
+		{"{{.Branch}}", SessionVars{Name: "wt"}, "wt"},
+		{"{{.Nope}}", SessionVars{Name: "wt"}, "wt"},
+	}
+	for _, tt := range tests {
+		if got := ResolveSessionName(tt.pattern, tt.vars); got != tt.want {
+			t.Errorf("ResolveSessionName(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestMatchSessionByOwner(t *testing.T) {
+	repo := t.TempDir()
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{SessionName: "folder"}, cmd)
+	s.mainOnce.Do(func() { s.mainRoot = repo })
+
+	owned := func(path string) *tmux.SessionInfo {
+		return &tmux.SessionInfo{Owner: tmux.Owner{Repo: repo, Worktree: path}}
+	}
+	snap := &tmux.Snapshot{
+		Names: []string{"api", "notes", "renamed", "stale", "web"},
+		Sessions: map[string]*tmux.SessionInfo{
+			"api":     owned("/src/other/api"),
+			"notes":   {},
+			"renamed": owned("/src/repo-feat"),
+			"stale":   owned("/src/repo-gone"),
+			"web":     {},
+		},
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/src/repo-feat", "renamed", true},
+		{"/src/web", "web", true},
+		{"/src/api", "api", false},
+		{"/src/docs", "docs", false},
+	}
+	claimed := map[string]bool{}
+	for _, tt := range tests {
+		name, ok := s.MatchSession(snap, git.Worktree{Path: tt.path})
+		if name != tt.want || ok != tt.ok {
+			t.Errorf("MatchSession(%s) = %s, %v; want %s, %v", tt.path, name, ok, tt.want, tt.ok)
+		}
+		if ok {
+			claimed[name] = true
+		}
+	}
+	// Untagged sessions and other repos' sessions are not orphans.
+	if got := Orphans(snap, claimed, map[string]bool{repo: true}); strings.Join(got, ",") != "api,stale" {
+		t.Fatalf("unexpected orphans: %v", got)
+	}
+}
+
+func TestRenameRollsBack(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	initRepo(t, repo)
+	old := filepath.Join(dir, "repo-old")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "old", old)
+
+	store, _ := recent.Load()
+	store.Add(repo, "repo-old", "repo-old", old)
+	rec := &recordingCommander{
+		outputs: map[string]string{"list-sessions": "repo-old:1:1700000000:\n"},
+		fail:    map[string]bool{"rename-session": true},
+	}
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: rec}, &config.Config{PathPattern: "sibling"}, cmd)
+	if _, err := s.Rename("old", "new", RenameOptions{Recent: store}); err == nil || !strings.Contains(err.Error(), "rename session") {
+		t.Fatalf("expected the session rename to fail, got %v", err)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("worktree was not moved back: %v", err)
+	}
+	runGit(t, repo, "rev-parse", "--verify", "--quiet", "refs/heads/old")
+	if store.Entries[0].Path != old {
+		t.Fatalf("recent entry changed despite the failure: %+v", store.Entries[0])
+	}
+
+	delete(rec.fail, "rename-session")
+	result, err := s.Rename("old", "new", RenameOptions{Recent: store})
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	newPath := filepath.Join(dir, "repo-new")
+	if result.Path != newPath || result.Branch != "new" || result.Session != "repo-new" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	runGit(t, repo, "rev-parse", "--verify", "--quiet", "refs/heads/new")
+	if e := store.Entries[0]; e.Path != newPath || e.Worktree != "repo-new" || e.SessionName != "repo-new" {
+		t.Fatalf("recent entry not updated: %+v", e)
+	}
+}
+
+func TestCreateRollsBack(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	initRepo(t, repo)
+
+	rec := &recordingCommander{fail: map[string]bool{"new-session": true}}
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: rec}, &config.Config{PathPattern: "sibling", BaseBranch: "main"}, cmd)
+	_, _, err := s.Create("feat", CreateOptions{})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "start session" || strings.Join(stepErr.RolledBack, ",") != "add worktree" {
+		t.Fatalf("expected the session start to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repo-feat")); !os.IsNotExist(err) {
+		t.Fatalf("worktree directory was left behind: %v", err)
+	}
+	if s.Git.BranchExists("feat") {
+		t.Fatal("branch was left behind")
+	}
+	if out := runGit(t, repo, "worktree", "list"); strings.Contains(out, "repo-feat") {
+		t.Fatalf("worktree still registered:\n%s", out)
+	}
+}
+
+func TestDeleteWorktreeRestoresSession(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	initRepo(t, repo)
+	feat := filepath.Join(dir, "repo-feat")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feat", feat)
+
+	rec := &recordingCommander{outputs: map[string]string{"list-sessions": "repo-feat:1:1700000000:\n"}}
+	cmd := &failingGitCommander{fail: "worktree remove"}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: killingCommander{rec}}, &config.Config{PathPattern: "sibling"}, cmd)
+	_, err := s.DeleteWorktree(feat, DeleteOptions{Force: true})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "remove worktree" || strings.Join(stepErr.RolledBack, ",") != "kill session" {
+		t.Fatalf("expected the worktree removal to fail, got %v", err)
+	}
+	var killed, restored bool
+	for _, call := range rec.calls {
+		switch {
+		case call[1] == "kill-session":
+			killed = true
+		case call[1] == "new-session" && killed:
+			restored = true
+		}
+	}
+	if !killed || !restored {
+		t.Fatalf("session was not restored after the kill: %v", rec.calls)
+	}
+	if _, err := os.Stat(feat); err != nil {
+		t.Fatalf("worktree removed: %v", err)
+	}
+}
+
+func TestAdoptOrphanRollsBack(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	initRepo(t, repo)
+
+	rec := &recordingCommander{fail: map[string]bool{"send-keys": true}}
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: rec}, &config.Config{PathPattern: "sibling"}, cmd)
+	_, err := s.AdoptOrphan("orphan", "main")
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "cd session" {
+		t.Fatalf("expected the cd to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repo-orphan")); !os.IsNotExist(err) {
+		t.Fatalf("worktree directory was left behind: %v", err)
+	}
+	if s.Git.BranchExists("orphan") {
+		t.Fatal("branch was left behind")
+	}
+}
+
+// killingCommander drops the listed sessions once one is killed.
+type killingCommander struct {
+	*recordingCommander
+}
+
+func (k killingCommander) Run(name string, args ...string) ([]byte, error) {
+	if len(args) > 0 && args[0] == "kill-session" {
+		delete(k.outputs, "list-sessions")
+	}
+	return k.recordingCommander.Run(name, args...)
+}
+
+// failingGitCommander runs commands for real, except git commands starting
+// with fail.
+type failingGitCommander struct {
+	shell.ExecCommander
+	fail string
+}
+
+func (f *failingGitCommander) RunEnv(dir string, env []string, name string, args ...string) ([]byte, error) {
+	if name == "git" && strings.HasPrefix(strings.Join(args, " "), f.fail) {
+		return []byte("fatal: " + f.fail + " failed"), errors.New("exit status 128")
+	}
+	return f.ExecCommander.RunEnv(dir, env, name, args...)
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
 	cmd.Dir = dir
-	return cmd
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// initRepo creates a repository at path with one empty commit on main.
+func initRepo(t *testing.T, path string) {
+	t.Helper()
+	runGit(t, filepath.Dir(path), "init", "-q", "-b", "main", path)
+	runGit(t, path, "commit", "-q", "--allow-empty", "-m", "init")
+}
+
+type recordingCommander struct {
+	calls   [][]string
+	outputs map[string]string
+	fail    map[string]bool
+}
+
+func (r *recordingCommander) Run(name string, args ...string) ([]byte, error) {
+	call := append([]string{name}, args...)
+	r.calls = append(r.calls, call)
+	if len(args) > 0 && r.fail[args[0]] {
+		return nil, errors.New(args[0] + " failed")
+	}
+	if len(args) > 0 {
+		if out, ok := r.outputs[args[0]]; ok {
+			return []byte(out), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *recordingCommander) RunDir(dir, name string, args ...string) ([]byte, error) {
+	return r.Run(name, args...)
+}
+
+func (r *recordingCommander) RunEnv(dir string, env []string, name string, args ...string) ([]byte, error) {
+	return r.Run(name, args...)
+}
+
+func TestStartSessionBuildsLayout(t *testing.T) {
+	rec := &recordingCommander{outputs: map[string]string{
+		"new-session":  "@1\n",
+		"new-window":   "@2\n",
+		"split-window": "%5\n",
+	}}
+	cfg := &config.Config{Layout: &config.Layout{Windows: []config.LayoutWindow{
+		{Name: "editor", Command: "nvim"},
+		{Name: "dev", Dir: "web", Layout: "even-horizontal", Panes: []config.LayoutPane{
+			{Command: "npm test", Split: "horizontal", Size: "40%"},
+		}},
+	}}}
+	s := NewService(&git.Git{RepoRoot: "/repo", Cmd: rec}, &tmux.Tmux{Cmd: rec}, cfg, rec)
+	if err := s.StartSession("wt", "/repo-wt"); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	want := [][]string{
+		{"tmux", "new-session", "-d", "-s", "wt", "-c", "/repo-wt", "-P", "-F", "#{window_id}", "-n", "editor"},
+		{"tmux", "send-keys", "-t", "@1", "nvim", "Enter"},
+		{"tmux", "new-window", "-d", "-t", "wt:", "-c", "/repo-wt/web", "-P", "-F", "#{window_id}", "-n", "dev"},
+		{"tmux", "split-window", "-d", "-t", "@2", "-c", "/repo-wt/web", "-P", "-F", "#{pane_id}", "-h", "-l", "40%"},
+		{"tmux", "send-keys", "-t", "%5", "npm test", "Enter"},
+		{"tmux", "select-layout", "-t", "@2", "even-horizontal"},
+		{"tmux", "select-window", "-t", "@1"},
+		{"tmux", "set-option", "-q", "-t", "wt", "@treemux_repo", "/repo", ";", "set-option", "-q", "-t", "wt", "@treemux_worktree", "/repo-wt", ";", "set-option", "-q", "-t", "wt", "@treemux_branch", ""},
+	}
+	assertTmuxCalls(t, rec.calls, want)
+}
+
+// assertTmuxCalls compares the tmux calls among calls with want, ignoring
+// git calls.
+func assertTmuxCalls(t *testing.T, calls, want [][]string) {
+	t.Helper()
+	var got [][]string
+	for _, c := range calls {
+		if c[0] == "tmux" {
+			got = append(got, c)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tmux calls, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if strings.Join(got[i], " ") != strings.Join(want[i], " ") {
+			t.Fatalf("call %d mismatch:\n got  %v\n want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRestoreSession(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	rec := &recordingCommander{
+		outputs: map[string]string{
+			"new-session":  "@1\n",
+			"new-window":   "@2\n",
+			"split-window": "%5\n",
+		},
+	}
+	cfg := &config.Config{Restore: config.Restore{Commands: []string{"nvim", "tail"}}}
+	s := NewService(&git.Git{RepoRoot: "/repo", Cmd: rec}, &tmux.Tmux{Cmd: rec}, cfg, rec)
+	saved := sessions.Session{Path: "/repo-wt", Windows: []sessions.Window{
+		{Name: "editor", Layout: "b25d,80x24,0,0,1", Panes: []sessions.Pane{{Dir: dir, Command: "nvim main.go"}}},
+		{Name: "dev", Layout: "tiled", Active: true, Panes: []sessions.Pane{
+			{Dir: "/gone", Command: "npm run dev"},
+			{Dir: dir, Command: "tail -f log", Active: true},
+		}},
+	}}
+	name, err := s.RestoreSession(saved)
+	if err != nil || name != "repo-wt" {
+		t.Fatalf("restore session: %s %v", name, err)
+	}
+
+	want := [][]string{
+		{"tmux", "list-sessions", "-F", "#{session_name}:#{session_windows}:#{session_activity}:#{@treemux_repo}"},
+		{"tmux", "list-panes", "-a", "-F", "#{session_name}:#{pane_pid}"},
+		{"tmux", "list-clients", "-F", "#{client_session}:#{client_control_mode}"},
+		{"tmux", "new-session", "-d", "-s", "repo-wt", "-c", dir, "-P", "-F", "#{window_id}", "-n", "editor"},
+		{"tmux", "select-layout", "-t", "@1", "b25d,80x24,0,0,1"},
+		{"tmux", "send-keys", "-t", "@1", "nvim main.go", "Enter"},
+		{"tmux", "new-window", "-d", "-t", "repo-wt:", "-c", "/repo-wt", "-P", "-F", "#{window_id}", "-n", "dev"},
+		{"tmux", "split-window", "-d", "-t", "@2", "-c", dir, "-P", "-F", "#{pane_id}", "-v"},
+		{"tmux", "select-layout", "-t", "@2", "tiled"},
+		{"tmux", "select-layout", "-t", "@2", "tiled"},
+		{"tmux", "send-keys", "-t", "%5", "tail -f log", "Enter"},
+		{"tmux", "select-pane", "-t", "%5"},
+		{"tmux", "select-window", "-t", "@2"},
+		{"tmux", "set-option", "-q", "-t", "repo-wt", "@treemux_repo", "/repo", ";", "set-option", "-q", "-t", "repo-wt", "@treemux_worktree", "/repo-wt", ";", "set-option", "-q", "-t", "repo-wt", "@treemux_branch", ""},
+	}
+	assertTmuxCalls(t, rec.calls, want)
+}
+
+func TestHooksReceiveEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	wt := filepath.Join(dir, "repo-feature")
+	if err := os.MkdirAll(wt, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cmd := &shell.ExecCommander{}
+	cfg := &config.Config{Hooks: config.Hooks{
+		PostCreate: []string{`echo "$TREEMUX_EVENT $TREEMUX_REPO_NAME $TREEMUX_WORKTREE_NAME $TREEMUX_BRANCH $TREEMUX_SESSION" > hook.out`},
+		PreDelete:  []string{"echo refusing; exit 3", "touch never-ran"},
+	}}
+	s := NewService(&git.Git{RepoRoot: filepath.Join(dir, "repo"), Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, cfg, cmd)
+	var log strings.Builder
+	s.HookLog = &log
+
+	if err := s.runHooks(HookPostCreate, hookTarget{Path: wt, Branch: "feature", Session: "repo-feature"}); err != nil {
+		t.Fatalf("post_create hook: %v", err)
+	}
+	out, err := os.ReadFile(filepath.Join(wt, "hook.out"))
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "post_create repo repo-feature feature repo-feature" {
+		t.Fatalf("hook env mismatch: %q", got)
+	}
+
+	err = s.runHooks(HookPreDelete, hookTarget{Path: wt})
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) || !hookErr.Aborts() || hookErr.Command != "echo refusing; exit 3" {
+		t.Fatalf("expected aborting pre_delete hook error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "never-ran")); err == nil {
+		t.Fatalf("hooks after a failure should not run")
+	}
+	if !strings.Contains(log.String(), "refusing") {
+		t.Fatalf("hook output missing from log: %q", log.String())
+	}
+}
+
+func TestDeleteWorktreeBranchCleanup(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	remote := filepath.Join(dir, "remote.git")
+	runGit(t, dir, "init", "-q", "--bare", remote)
+	initRepo(t, repo)
+	runGit(t, repo, "remote", "add", "origin", remote)
+	runGit(t, repo, "push", "-q", "-u", "origin", "main")
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{BaseBranch: "main"}, cmd)
+	s.HookLog = &strings.Builder{}
+	add := func(branch string, commit bool) string {
+		path := filepath.Join(dir, branch)
+		runGit(t, repo, "worktree", "add", "-q", "-b", branch, path, "main")
+		if commit {
+			runGit(t, path, "commit", "-q", "--allow-empty", "-m", "work")
+		}
+		runGit(t, path, "push", "-q", "-u", "origin", branch)
+		return path
+	}
+
+	merged := add("merged", false)
+	info := s.BranchInfo(merged)
+	if !info.Merged || info.Remote != "origin" || info.RemoteBranch != "merged" {
+		t.Fatalf("unexpected branch info: %+v", info)
+	}
+	result, err := s.DeleteWorktree(merged, DeleteOptions{Branch: DeleteMergedBranch, DeleteRemote: true})
+	if err != nil {
+		t.Fatalf("delete merged: %v", err)
+	}
+	if !result.BranchDeleted || result.DeletedRemote != "origin/merged" {
+		t.Fatalf("merged branch not cleaned up: %+v", result)
+	}
+	if s.Git.BranchExists("merged") || s.Git.RefExists("refs/remotes/origin/merged") {
+		t.Fatalf("merged branch still exists")
+	}
+
+	unmerged := add("unmerged", true)
+	result, err = s.DeleteWorktree(unmerged, DeleteOptions{Branch: DeleteMergedBranch, DeleteRemote: true})
+	if err != nil {
+		t.Fatalf("delete unmerged: %v", err)
+	}
+	if result.BranchDeleted || result.KeptReason == "" || !s.Git.BranchExists("unmerged") {
+		t.Fatalf("unmerged branch should be kept: %+v", result)
+	}
+
+	locked := add("locked", false)
+	runGit(t, repo, "worktree", "lock", "--reason", "on a usb stick", locked)
+	check, err := s.CheckDelete(locked)
+	if err != nil {
+		t.Fatalf("check locked: %v", err)
+	}
+	if problems := check.Problems(); len(problems) != 1 || problems[0] != "locked: on a usb stick" {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if _, err := s.DeleteWorktree(locked, DeleteOptions{Force: true}); !errors.Is(err, git.ErrWorktreeLocked) {
+		t.Fatalf("expected ErrWorktreeLocked, got %v", err)
+	}
+	if _, err := os.Stat(locked); err != nil {
+		t.Fatalf("locked worktree removed: %v", err)
+	}
+
+	if _, err := s.DeleteWorktree(repo, DeleteOptions{Force: true}); err == nil || !strings.Contains(err.Error(), "main worktree") {
+		t.Fatalf("expected the main worktree to be refused, got %v", err)
+	}
+}
+
+func TestDeleteCheckProblems(t *testing.T) {
+	tests := []struct {
+		name  string
+		check DeleteCheck
+		want  []string
+	}{
+		{"clean", DeleteCheck{Status: &git.StatusSummary{Clean: true}}, nil},
+		{"no status", DeleteCheck{}, nil},
+		{"dirty", DeleteCheck{Status: &git.StatusSummary{Conflicted: 1, Staged: 2, Modified: 3, Untracked: 4}},
+			[]string{"uncommitted changes (1 conflicted, 2 staged, 3 modified, 4 untracked)"}},
+		{"unpushed", DeleteCheck{Status: &git.StatusSummary{Clean: true}, Unpushed: 2}, []string{"2 unpushed commit(s)"}},
+		{"processes", DeleteCheck{Processes: []string{"node", "nvim"}}, []string{"running processes: node, nvim"}},
+		{"locked", DeleteCheck{Worktree: git.Worktree{Locked: true}}, []string{"locked"}},
+		{"locked with reason", DeleteCheck{Worktree: git.Worktree{Locked: true, LockReason: "usb"}}, []string{"locked: usb"}},
+		{"everything", DeleteCheck{
+			Worktree:  git.Worktree{Locked: true},
+			Status:    &git.StatusSummary{Untracked: 1},
+			Unpushed:  1,
+			Processes: []string{"make"},
+		}, []string{"locked", "uncommitted changes (0 conflicted, 0 staged, 0 modified, 1 untracked)", "1 unpushed commit(s)", "running processes: make"}},
+	}
+	for _, tt := range tests {
+		if got := tt.check.Problems(); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDelete(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	remote := filepath.Join(dir, "remote.git")
+	initRepo(t, repo)
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: &recordingCommander{}}, &config.Config{BaseBranch: "main"}, cmd)
+	feat := filepath.Join(dir, "repo-feat")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feat/x", feat)
+	runGit(t, feat, "commit", "-q", "--allow-empty", "-m", "work")
+
+	for _, query := range []string{feat, "repo-feat", "feat/x"} {
+		wt, err := s.FindWorktree(query)
+		if err != nil || wt.Path != feat {
+			t.Fatalf("FindWorktree(%q) = %+v, %v", query, wt, err)
+		}
+	}
+	if _, err := s.FindWorktree("missing"); err == nil {
+		t.Fatal("expected an error for an unknown worktree")
+	}
+
+	// Without remotes nothing counts as unpushed.
+	check, err := s.CheckDelete("repo-feat")
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if check.Unpushed != 0 || len(check.Problems()) != 0 {
+		t.Fatalf("unexpected check without remotes: %+v %v", check, check.Problems())
+	}
+
+	// Without an upstream, commits on no remote count.
+	runGit(t, dir, "init", "-q", "--bare", remote)
+	runGit(t, repo, "remote", "add", "origin", remote)
+	runGit(t, repo, "push", "-q", "origin", "main")
+	if check, _ = s.CheckDelete(feat); check.Unpushed != 1 {
+		t.Fatalf("expected 1 commit on no remote, got %d", check.Unpushed)
+	}
+
+	// With an upstream, commits ahead of it count.
+	runGit(t, feat, "push", "-q", "-u", "origin", "feat/x")
+	runGit(t, feat, "commit", "-q", "--allow-empty", "-m", "more")
+	if err := os.WriteFile(filepath.Join(feat, "wip.txt"), []byte("wip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	check, err = s.CheckDelete("feat/x")
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	want := []string{"uncommitted changes (0 conflicted, 0 staged, 0 modified, 1 untracked)", "1 unpushed commit(s)"}
+	if got := check.Problems(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got problems %q, want %q", got, want)
+	}
+}
+
+func TestClassifyWorktrees(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	remote := filepath.Join(dir, "remote.git")
+	runGit(t, dir, "init", "-q", "--bare", remote)
+	initRepo(t, repo)
+	runGit(t, repo, "remote", "add", "origin", remote)
+	add := func(branch string) string {
+		path := filepath.Join(dir, branch)
+		runGit(t, repo, "worktree", "add", "-q", "-b", branch, path, "main")
+		runGit(t, path, "commit", "-q", "--allow-empty", "-m", branch)
+		return path
+	}
+	merged := add("merged")
+	runGit(t, repo, "merge", "-q", "--ff-only", "merged")
+	gone := add("gone")
+	runGit(t, gone, "push", "-q", "-u", "origin", "gone")
+	runGit(t, gone, "push", "-q", "origin", "--delete", "gone")
+	squash := func(branch string) string {
+		path := add(branch)
+		if err := os.WriteFile(filepath.Join(path, branch+".txt"), []byte(branch), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, path, "add", ".")
+		runGit(t, path, "commit", "-q", "-m", branch)
+		runGit(t, path, "push", "-q", "-u", "origin", branch)
+		runGit(t, repo, "merge", "-q", "--squash", branch)
+		runGit(t, repo, "commit", "-q", "-m", "squash "+branch)
+		runGit(t, path, "push", "-q", "origin", "--delete", branch)
+		return path
+	}
+	squashed := squash("squashed")
+	// Work committed after the squash merge must survive a prune.
+	reworked := squash("reworked")
+	if err := os.WriteFile(filepath.Join(reworked, "more.txt"), []byte("more"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, reworked, "add", ".")
+	runGit(t, reworked, "commit", "-q", "-m", "more")
+	dirty := add("dirty")
+	if err := os.WriteFile(filepath.Join(dirty, "wip.txt"), []byte("wip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fresh := filepath.Join(dir, "fresh")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "fresh", fresh, "main")
+	// Commits from here on are old enough to be stale.
+	t.Setenv("GIT_COMMITTER_DATE", "2020-01-01T00:00:00Z")
+	stale := add("stale")
+	locked := add("locked")
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: repo, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{BaseBranch: "main"}, cmd)
+	var states []WorktreeState
+	for _, path := range []string{repo, merged, gone, squashed, reworked, stale, dirty, fresh, locked} {
+		status, _ := s.Git.Status(path)
+		states = append(states, WorktreeState{Worktree: git.Worktree{Path: path, Name: filepath.Base(path), Locked: path == locked}, Status: status})
+	}
+	want := map[string]PruneReason{
+		"repo":     PruneActive,
+		"merged":   PruneMerged,
+		"gone":     PruneGone,
+		"squashed": PruneGone,
+		"reworked": PruneGone,
+		"stale":    PruneStale,
+		"dirty":    PruneActive,
+		"fresh":    PruneActive,
+		"locked":   PruneActive,
+	}
+	wantBranch := map[string]BranchCleanup{"squashed": ForceDeleteBranch}
+	for _, c := range s.Classify(states, DefaultStaleAfter) {
+		name := c.State.Worktree.Name
+		if got := c.Reason; got != want[name] {
+			t.Errorf("%s: got %s, want %s", name, got, want[name])
+		}
+		mode, ok := wantBranch[name]
+		if !ok {
+			mode = DeleteMergedBranch
+		}
+		if got := c.DeleteOptions(false).Branch; got != mode {
+			t.Errorf("%s: got branch mode %v, want %v", name, got, mode)
+		}
+	}
+
+	// Seen from a linked worktree, the main worktree is still never pruned.
+	runGit(t, repo, "checkout", "-q", "-b", "topic")
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "topic")
+	linked := NewService(&git.Git{RepoRoot: fresh, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{BaseBranch: "main"}, cmd)
+	status, _ := linked.Git.Status(repo)
+	main := WorktreeState{Worktree: git.Worktree{Path: repo, Name: "repo"}, Status: status}
+	if got := linked.Classify([]WorktreeState{main}, DefaultStaleAfter)[0].Reason; got != PruneActive {
+		t.Errorf("main worktree from a linked one: got %s, want %s", got, PruneActive)
+	}
+}
+
+func TestGitStateCachedUntilRepositoryChanges(t *testing.T) {
+	dir := t.TempDir()
+	initRepo(t, dir)
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: dir, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{}, cmd)
+	if gs := s.gitState(dir); gs.status == nil || !gs.status.Clean {
+		t.Fatalf("expected clean status, got %+v", gs.status)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if gs := s.gitState(dir); !gs.status.Clean {
+		t.Fatalf("expected cached status while the index is unchanged")
+	}
+	runGit(t, dir, "add", "a.txt")
+	if gs := s.gitState(dir); gs.status.Staged != 1 {
+		t.Fatalf("expected refreshed status after staging, got %+v", gs.status)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.Invalidate(dir)
+	if gs := s.gitState(dir); gs.status.Untracked != 1 {
+		t.Fatalf("expected refreshed status after Invalidate, got %+v", gs.status)
+	}
+
+	runGit(t, dir, "remote", "add", "origin", filepath.Join(dir, "remote.git"))
+	runGit(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	runGit(t, dir, "branch", "-q", "--set-upstream-to", "origin/main")
+	if gs := s.gitState(dir); gs.status.Upstream != "origin/main" {
+		t.Fatalf("expected refreshed status after setting the upstream, got %+v", gs.status)
+	}
+	runGit(t, dir, "commit", "-q", "--allow-empty", "-m", "second")
+	if gs := s.gitState(dir); gs.ahead != 1 || len(gs.commits) != 2 {
+		t.Fatalf("expected refreshed state after a commit, got ahead %d and %d commits", gs.ahead, len(gs.commits))
+	}
+	runGit(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	if gs := s.gitState(dir); gs.ahead != 0 {
+		t.Fatalf("expected refreshed state after the upstream moved, got ahead %d", gs.ahead)
+	}
+}
+
+func TestWatcherSkipsIgnoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "build/\n*.log\n")
+	write("src/main.go", "package main")
+	write("build/out.bin", "")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+
+	cmd := &shell.ExecCommander{}
+	s := NewService(&git.Git{RepoRoot: dir, Cmd: cmd}, &tmux.Tmux{Cmd: cmd}, &config.Config{}, cmd)
+	w, err := s.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Set([]string{dir})
+
+	expect := func(changed bool) {
+		t.Helper()
+		select {
+		case path := <-w.Changes():
+			if !changed {
+				t.Fatalf("unexpected change for %s", path)
+			}
+		case <-time.After(3 * watchDebounce):
+			if changed {
+				t.Fatalf("expected a change")
+			}
+		}
+	}
+
+	write("build/other.bin", "x")
+	write("src/debug.log", "x")
+	expect(false)
+	write("src/main.go", "x")
+	expect(true)
+	write("src/pkg/new.go", "x")
+	expect(true)
+	write("src/pkg/again.go", "x")
+	expect(true)
+	runGit(t, dir, "add", ".")
+	expect(true)
 }